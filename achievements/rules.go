@@ -0,0 +1,114 @@
+package achievements
+
+// Trigger identifica el evento que provoca la evaluación de las reglas
+type Trigger string
+
+const (
+	MatchCompleted     Trigger = "match_completed"
+	TournamentFinished Trigger = "tournament_finished"
+)
+
+// GiantSlayerMinGap es la diferencia mínima de puntos para considerar que el rival
+// derrotado tenía un nivel superior
+const GiantSlayerMinGap = 50
+
+// Facts son los datos de un usuario sobre los que se evalúan las reglas
+type Facts struct {
+	UserID              int
+	MatchesWon          int
+	TournamentsPlayed   int
+	TournamentsWon      int
+	FlawlessTournaments int
+	// Al reportar un match se calcula con los puntos de ese momento; en el backfill, con
+	// los puntos reconstruidos a partir de los torneos empezados y las finales jugadas
+	// antes del match
+	BeatHigherRated bool
+}
+
+// Rule es un logro desbloqueable
+type Rule struct {
+	Code        string
+	Name        string
+	Description string
+	Triggers    []Trigger
+	Check       func(f Facts) bool
+}
+
+var Rules = []Rule{
+	{
+		Code:        "first_win",
+		Name:        "Primera victoria",
+		Description: "Gana tu primer match",
+		Triggers:    []Trigger{MatchCompleted},
+		Check:       func(f Facts) bool { return f.MatchesWon >= 1 },
+	},
+	{
+		Code:        "first_tournament_won",
+		Name:        "Primer torneo ganado",
+		Description: "Proclámate campeón de un torneo",
+		Triggers:    []Trigger{TournamentFinished},
+		Check:       func(f Facts) bool { return f.TournamentsWon >= 1 },
+	},
+	{
+		Code:        "tournaments_played_10",
+		Name:        "Veterano",
+		Description: "Juega 10 torneos",
+		Triggers:    []Trigger{MatchCompleted, TournamentFinished},
+		Check:       func(f Facts) bool { return f.TournamentsPlayed >= 10 },
+	},
+	{
+		Code:        "flawless_run",
+		Name:        "Ronda perfecta",
+		Description: "Gana un torneo jugando todas las rondas, sin pases por BYE",
+		Triggers:    []Trigger{TournamentFinished},
+		Check:       func(f Facts) bool { return f.FlawlessTournaments >= 1 },
+	},
+	{
+		Code:        "giant_slayer",
+		Name:        "Matagigantes",
+		Description: "Derrota a un rival con al menos 50 puntos más que tú",
+		Triggers:    []Trigger{MatchCompleted},
+		Check:       func(f Facts) bool { return f.BeatHigherRated },
+	},
+}
+
+func (r Rule) handles(trigger Trigger) bool {
+	for _, t := range r.Triggers {
+		if t == trigger {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate devuelve las reglas asociadas al trigger que se cumplen con los datos dados
+func Evaluate(trigger Trigger, f Facts) []Rule {
+	var unlocked []Rule
+	for _, r := range Rules {
+		if r.handles(trigger) && r.Check(f) {
+			unlocked = append(unlocked, r)
+		}
+	}
+	return unlocked
+}
+
+// EvaluateAll evalúa todas las reglas sin tener en cuenta el trigger (backfill)
+func EvaluateAll(f Facts) []Rule {
+	var unlocked []Rule
+	for _, r := range Rules {
+		if r.Check(f) {
+			unlocked = append(unlocked, r)
+		}
+	}
+	return unlocked
+}
+
+// ByCode busca la definición de un logro por su código
+func ByCode(code string) (Rule, bool) {
+	for _, r := range Rules {
+		if r.Code == code {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,242 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"torneos/database"
+	"torneos/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	keyAction     = "audit_action"
+	keyTargetType = "audit_target_type"
+	keyTargetID   = "audit_target_id"
+	keyTournament = "audit_tournament_id"
+	keyBefore     = "audit_before"
+	keyAfter      = "audit_after"
+	keySkip       = "audit_skip"
+	keyOmitIP     = "audit_omit_ip"
+)
+
+// SetAction sustituye el nombre por defecto de la acción ("POST /api/...")
+func SetAction(c *gin.Context, action string) { c.Set(keyAction, action) }
+
+func SetTarget(c *gin.Context, targetType string, targetID int) {
+	c.Set(keyTargetType, targetType)
+	c.Set(keyTargetID, targetID)
+}
+
+func SetTournament(c *gin.Context, tournamentID int) { c.Set(keyTournament, tournamentID) }
+
+// SetBefore y SetAfter guardan el estado del objetivo antes y después del cambio. Solo
+// se registran los campos que difieren; sin SetAfter (borrado) se guarda todo el estado
+// anterior, y sin SetBefore (alta) todo el nuevo.
+func SetBefore(c *gin.Context, v interface{}) { c.Set(keyBefore, v) }
+func SetAfter(c *gin.Context, v interface{})  { c.Set(keyAfter, v) }
+
+// Skip evita que se registre la petición
+func Skip(c *gin.Context) { c.Set(keySkip, true) }
+
+// OmitIP registra la petición sin la IP del cliente
+func OmitIP(c *gin.Context) { c.Set(keyOmitIP, true) }
+
+// Rutas que modifican datos pero no se registran: renovar la sesión ocurre cada pocos
+// minutos y ya queda constancia en refresh_tokens
+var skipPaths = map[string]bool{
+	"/auth/refresh": true,
+}
+
+// Middleware guarda una entrada por cada petición POST, PUT, PATCH o DELETE con el
+// actor, la ruta, el objetivo (deducido de los parámetros de la ruta), la IP y el
+// código de respuesta. Los handlers pueden completar la entrada con SetAction,
+// SetTarget, SetTournament y, para guardar qué ha cambiado, SetBefore y SetAfter.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		// Se registran los cambios hechos y los intentos rechazados por falta de permisos;
+		// los errores de validación o del servidor no
+		status := c.Writer.Status()
+		if c.GetBool(keySkip) || skipPaths[c.FullPath()] || c.FullPath() == "" ||
+			(status >= 400 && status != http.StatusForbidden) {
+			return
+		}
+
+		if err := database.CreateAuditEntry(buildEntry(c, status)); err != nil {
+			log.Printf("Error guardando la auditoría de %s %s: %v", c.Request.Method, c.FullPath(), err)
+		}
+	}
+}
+
+// Record guarda una entrada creada por el propio handler. Lo usan los comandos de
+// Discord: todos llegan a POST /discord/interactions, que el middleware no registra.
+func Record(e *models.AuditEntry) {
+	if err := database.CreateAuditEntry(e); err != nil {
+		log.Printf("Error guardando la auditoría de %s: %v", e.Action, err)
+	}
+}
+
+func buildEntry(c *gin.Context, status int) *models.AuditEntry {
+	e := &models.AuditEntry{
+		Action:     c.GetString(keyAction),
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		IP:         c.ClientIP(),
+		StatusCode: status,
+	}
+	if c.GetBool(keyOmitIP) {
+		e.IP = ""
+	}
+	if e.Action == "" {
+		e.Action = c.Request.Method + " " + c.FullPath()
+	}
+	if userID := c.GetInt("user_id"); userID != 0 {
+		e.ActorUserID = &userID
+	}
+	_, e.ViaAPIToken = c.Get("api_token_scopes")
+
+	if t, ok := c.Get(keyTargetType); ok {
+		e.TargetType = t.(string)
+		id := c.GetInt(keyTargetID)
+		e.TargetID = &id
+	} else {
+		e.TargetType, e.TargetID = targetFromRoute(c)
+	}
+
+	if id, ok := c.Get(keyTournament); ok {
+		tid := id.(int)
+		e.TournamentID = &tid
+	} else {
+		e.TournamentID = tournamentFromRoute(c, e.TargetType, e.TargetID)
+	}
+
+	before, hasBefore := c.Get(keyBefore)
+	after, hasAfter := c.Get(keyAfter)
+	switch {
+	case hasBefore && hasAfter:
+		e.Before, e.After = Diff(before, after)
+	case hasBefore:
+		e.Before = marshal(before)
+	case hasAfter:
+		e.After = marshal(after)
+	}
+
+	return e
+}
+
+// Tipo de objetivo según el parámetro de la ruta. Para :id depende del recurso.
+var paramTargets = map[string]string{
+	"user_id":     "user",
+	"webhook_id":  "webhook",
+	"delivery_id": "webhook_delivery",
+	"ban_id":      "ban",
+	"report_id":   "user_report",
+	"token_id":    "api_token",
+}
+
+var idTargets = map[string]string{
+	"tournaments":   "tournament",
+	"matches":       "match",
+	"users":         "user",
+	"notifications": "notification",
+}
+
+// targetFromRoute toma como objetivo el último parámetro numérico de la ruta, que es
+// el recurso más concreto (/api/tournaments/:id/staff/:user_id → el usuario)
+func targetFromRoute(c *gin.Context) (string, *int) {
+	segments := strings.Split(c.FullPath(), "/")
+	for i := len(segments) - 1; i > 0; i-- {
+		name, ok := strings.CutPrefix(segments[i], ":")
+		if !ok {
+			continue
+		}
+
+		targetType := paramTargets[name]
+		if name == "id" {
+			targetType = idTargets[segments[i-1]]
+		}
+		id, err := strconv.Atoi(c.Param(name))
+		if targetType == "" || err != nil {
+			continue
+		}
+		return targetType, &id
+	}
+	return "", nil
+}
+
+func tournamentFromRoute(c *gin.Context, targetType string, targetID *int) *int {
+	if strings.HasPrefix(c.FullPath(), "/api/tournaments/:id") {
+		if id, err := strconv.Atoi(c.Param("id")); err == nil {
+			return &id
+		}
+	}
+	if targetType == "match" && targetID != nil {
+		if tournamentID, _, _, err := database.GetMatchPlayers(*targetID); err == nil {
+			return &tournamentID
+		}
+	}
+	return nil
+}
+
+// Diff devuelve los campos de primer nivel que cambian entre before y after, con sus
+// valores anteriores y nuevos
+func Diff(before, after interface{}) (json.RawMessage, json.RawMessage) {
+	b, a := toMap(before), toMap(after)
+	if b == nil || a == nil {
+		return marshal(before), marshal(after)
+	}
+
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !reflect.DeepEqual(v, w) {
+			changedBefore[k] = v
+		}
+	}
+	for k, w := range a {
+		if v, ok := b[k]; !ok || !reflect.DeepEqual(v, w) {
+			changedAfter[k] = w
+		}
+	}
+	if len(changedBefore) == 0 && len(changedAfter) == 0 {
+		return nil, nil
+	}
+	return marshal(changedBefore), marshal(changedAfter)
+}
+
+func toMap(v interface{}) map[string]interface{} {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if json.Unmarshal(raw, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+func marshal(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+
+	"torneos/models"
+)
+
+// Los tokens de API llevan este prefijo para distinguirlos de los JWT de sesión
+const apiTokenPrefix = "trn_"
+
+// NewAPIToken genera un token de API y devuelve el token, su hash y el prefijo visible
+func NewAPIToken() (token, hash, prefix string, err error) {
+	random, err := randomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	token = apiTokenPrefix + random
+	return token, hashToken(token), token[:len(apiTokenPrefix)+6], nil
+}
+
+func isAPIToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
+
+// ValidScopes indica si todos los permisos existen
+func ValidScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, s := range scopes {
+		if !slices.Contains(models.APITokenScopes, s) {
+			return false
+		}
+	}
+	return true
+}
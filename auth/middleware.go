@@ -1,15 +1,80 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"time"
+
+	"torneos/database"
+	"torneos/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+var (
+	ErrInvalidToken   = errors.New("token inválido")
+	ErrMalformedToken = errors.New("token mal formado")
+)
+
+// AuthenticateToken valida un JWT emitido por GenerateJWT y devuelve el user_id que
+// contiene y cuándo caduca, con las mismas comprobaciones que AuthMiddleware: el usuario
+// no puede estar suspendido ni borrado ni haber cerrado todas sus sesiones después de
+// emitirse el token. Lo usan las conexiones de tiempo real, que no pasan por el middleware
+// y dejan de estar autenticadas al caducar el token.
+func AuthenticateToken(tokenString string) (int, time.Time, error) {
+	userID, issuedAt, err := parseAccessToken(tokenString)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if err := database.CheckUserActive(userID, issuedAt); err != nil {
+		return 0, time.Time{}, err
+	}
+	// GenerateJWT fija la caducidad a AccessTokenTTL desde la emisión
+	return userID, issuedAt.Add(AccessTokenTTL), nil
+}
+
+// parseAccessToken devuelve además cuándo se emitió el token, para poder invalidarlo
+// si el usuario cierra todas sus sesiones
+func parseAccessToken(tokenString string) (int, time.Time, error) {
+	secret := os.Getenv("JWT_SECRET")
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(secret), nil
+	})
+
+	if err != nil || !token.Valid {
+		return 0, time.Time{}, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["user_id"] == nil {
+		return 0, time.Time{}, ErrMalformedToken
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, time.Time{}, ErrMalformedToken
+	}
+
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return 0, time.Time{}, ErrMalformedToken
+	}
+
+	return int(userID), issuedAt.Time, nil
+}
+
+// AuthMiddleware exige un token de sesión. Los tokens de API solo se aceptan en las
+// rutas que indican el permiso necesario, p. ej. AuthMiddleware(models.ScopeRead);
+// el resto de rutas (gestión de la cuenta, tokens, etc.) los rechazan.
+func AuthMiddleware(scope ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
@@ -18,27 +83,90 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		secret := os.Getenv("JWT_SECRET")
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secret), nil
-		})
+		if isAPIToken(tokenString) {
+			authenticateAPIToken(c, tokenString, scope)
+			return
+		}
 
-		if err != nil || !token.Valid {
+		userID, issuedAt, err := parseAccessToken(tokenString)
+		if errors.Is(err, ErrMalformedToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token mal formado"})
+			return
+		}
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || claims["user_id"] == nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token mal formado"})
+		if !checkUserActive(c, userID, issuedAt) {
 			return
 		}
 
-		c.Set("user_id", int(claims["user_id"].(float64))) // guardar user_id en contexto
+		c.Set("user_id", userID) // guardar user_id en contexto
 		c.Next()
 	}
 }
+
+func authenticateAPIToken(c *gin.Context, tokenString string, scope []string) {
+	if len(scope) == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Esta ruta no admite tokens de API"})
+		return
+	}
+
+	userID, scopes, err := database.UseAPIToken(hashToken(tokenString))
+	if errors.Is(err, database.ErrAPITokenInvalid) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error comprobando el token"})
+		return
+	}
+
+	for _, s := range scope {
+		if !slices.Contains(scopes, s) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "El token no tiene el permiso " + s})
+			return
+		}
+	}
+
+	// Cerrar todas las sesiones no afecta a los tokens de API, que se revocan aparte
+	if !checkUserActive(c, userID, time.Now()) {
+		return
+	}
+
+	c.Set("user_id", userID)
+	c.Set("api_token_scopes", scopes)
+	c.Next()
+}
+
+// checkUserActive rechaza tokens válidos de usuarios suspendidos, borrados o que han
+// cerrado todas sus sesiones después de emitirse el token
+func checkUserActive(c *gin.Context, userID int, issuedAt time.Time) bool {
+	switch err := database.CheckUserActive(userID, issuedAt); {
+	case errors.Is(err, database.ErrUserBanned):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Usuario suspendido"})
+		return false
+	case errors.Is(err, database.ErrUserNotFound), errors.Is(err, database.ErrSessionRevoked):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Sesión no válida"})
+		return false
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error comprobando la sesión"})
+		return false
+	}
+	return true
+}
+
+// OptionalAuthMiddleware identifica al usuario si envía token y deja pasar sin sesión
+// si no lo hace; en ese caso user_id queda a 0. Un token inválido sigue siendo un error.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	required := AuthMiddleware(models.ScopeRead)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		required(c)
+	}
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"torneos/models"
+)
+
+// OAuthProvider es un proveedor externo de inicio de sesión
+type OAuthProvider interface {
+	Name() string
+	// AuthURL devuelve la URL a la que se redirige al usuario para autorizar el acceso.
+	// codeChallenge es el reto PKCE; los proveedores que no lo admiten lo ignoran.
+	AuthURL(state, codeChallenge string) string
+	// Callback valida los parámetros con los que el proveedor vuelve a /auth/:provider/callback
+	// y devuelve la cuenta del usuario en el proveedor
+	Callback(ctx context.Context, params url.Values, codeVerifier string) (*models.UserIdentity, error)
+}
+
+var (
+	providers = map[string]OAuthProvider{}
+
+	ErrUnknownProvider = errors.New("proveedor de inicio de sesión desconocido")
+)
+
+func RegisterProvider(p OAuthProvider) {
+	providers[p.Name()] = p
+}
+
+func GetProvider(name string) (OAuthProvider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// OAuth2Provider implementa el flujo authorization code de OAuth2. Los endpoints son
+// configurables para poder apuntar a un servidor de pruebas.
+type OAuth2Provider struct {
+	ProviderName string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// PKCE envía code_challenge y code_verifier (S256)
+	PKCE bool
+
+	AuthEndpoint     string
+	TokenEndpoint    string
+	UserInfoEndpoint string
+
+	// Cabeceras extra para la petición de perfil (Twitch exige Client-Id)
+	UserInfoHeaders map[string]string
+
+	// ParseIdentity convierte la respuesta del endpoint de perfil en la identidad
+	ParseIdentity func(body []byte) (*models.UserIdentity, error)
+}
+
+func (p *OAuth2Provider) Name() string { return p.ProviderName }
+
+func (p *OAuth2Provider) AuthURL(state, codeChallenge string) string {
+	q := url.Values{}
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("response_type", "code")
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	if state != "" {
+		q.Set("state", state)
+	}
+	if p.PKCE && codeChallenge != "" {
+		q.Set("code_challenge", codeChallenge)
+		q.Set("code_challenge_method", "S256")
+	}
+	return p.AuthEndpoint + "?" + q.Encode()
+}
+
+func (p *OAuth2Provider) Callback(ctx context.Context, params url.Values, codeVerifier string) (*models.UserIdentity, error) {
+	// El usuario ha cancelado o el proveedor ha rechazado la petición
+	if e := params.Get("error"); e != "" {
+		if desc := params.Get("error_description"); desc != "" {
+			e += ": " + desc
+		}
+		return nil, fmt.Errorf("%s devolvió un error: %s", p.ProviderName, e)
+	}
+
+	code := params.Get("code")
+	if code == "" {
+		return nil, errors.New("no se proporcionó el código de autorización")
+	}
+
+	accessToken, err := p.exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.userInfo(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := p.ParseIdentity(body)
+	if err != nil {
+		return nil, err
+	}
+	if identity.ProviderUserID == "" {
+		return nil, fmt.Errorf("%s no ha devuelto el ID del usuario", p.ProviderName)
+	}
+	identity.Provider = p.ProviderName
+	return identity, nil
+}
+
+// exchange canjea el código de autorización por un access token
+func (p *OAuth2Provider) exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	if p.PKCE && codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	body, err := doOAuthRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", errors.New("respuesta de token inválida")
+	}
+	return token.AccessToken, nil
+}
+
+func (p *OAuth2Provider) userInfo(ctx context.Context, accessToken string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.UserInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	for k, v := range p.UserInfoHeaders {
+		req.Header.Set(k, v)
+	}
+
+	return doOAuthRequest(req)
+}
+
+// doOAuthRequest devuelve el cuerpo de la respuesta; cualquier estado que no sea 2xx
+// es un error
+func doOAuthRequest(req *http.Request) ([]byte, error) {
+	res, err := oauthClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s respondió %d", req.URL.Host, res.StatusCode)
+	}
+	return body, nil
+}
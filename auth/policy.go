@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"torneos/database"
+	"torneos/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Roles globales y por torneo
+const (
+	RoleUser      = "user"
+	RoleAdmin     = "admin"
+	RoleOwner     = "owner"
+	RoleOrganizer = "organizer"
+	RoleReferee   = "referee"
+)
+
+// Action es una operación protegida sobre un torneo
+type Action string
+
+const (
+	EditTournament   Action = "edit_tournament"
+	DeleteTournament Action = "delete_tournament"
+	GenerateBracket  Action = "generate_bracket"
+	ReportResult     Action = "report_result"
+	ManageStaff      Action = "manage_staff"
+	ManageWebhooks   Action = "manage_webhooks"
+	ManageBans       Action = "manage_bans"
+	ViewAudit        Action = "view_audit"
+)
+
+// Acciones permitidas para cada rol del torneo. Los administradores pueden hacerlo todo.
+var tournamentPermissions = map[string][]Action{
+	RoleOwner:     {EditTournament, DeleteTournament, GenerateBracket, ReportResult, ManageStaff, ManageWebhooks, ManageBans, ViewAudit},
+	RoleOrganizer: {EditTournament, GenerateBracket, ReportResult, ManageWebhooks, ManageBans, ViewAudit},
+	RoleReferee:   {ReportResult},
+}
+
+// IsValidStaffRole indica si el rol puede asignarse al staff de un torneo
+func IsValidStaffRole(role string) bool {
+	return role == RoleOrganizer || role == RoleReferee
+}
+
+func IsAdmin(userID int) (bool, error) {
+	role, err := database.GetUserRole(userID)
+	if err != nil {
+		return false, err
+	}
+	return role == RoleAdmin, nil
+}
+
+// CanTournament comprueba si el usuario puede realizar la acción sobre el torneo.
+// Devuelve error si el torneo no existe.
+func CanTournament(userID, tournamentID int, action Action) (bool, error) {
+	role, err := database.GetTournamentRole(tournamentID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, a := range tournamentPermissions[role] {
+		if a == action {
+			return true, nil
+		}
+	}
+
+	return IsAdmin(userID)
+}
+
+// CanReportMatch comprueba si el usuario puede reportar el resultado de un match:
+// los dos jugadores y el staff del torneo con permiso para reportar.
+func CanReportMatch(userID, matchID int) (bool, error) {
+	tournamentID, player1ID, player2ID, err := database.GetMatchPlayers(matchID)
+	if err != nil {
+		return false, err
+	}
+
+	// Un usuario sancionado en el torneo no puede reportar, ni como jugador ni como staff
+	banned, err := database.IsUserBanned(userID, tournamentID)
+	if err != nil || banned {
+		return false, err
+	}
+
+	if userID == player1ID || userID == player2ID {
+		return true, nil
+	}
+
+	return CanTournament(userID, tournamentID, ReportResult)
+}
+
+// CanBanFromTournament comprueba si actorID, que ya puede gestionar las sanciones del
+// torneo, puede sancionar a targetID en él: al dueño y al staff solo los sancionan el
+// dueño o un administrador.
+func CanBanFromTournament(actorID, targetID, tournamentID int) (bool, error) {
+	targetRole, err := database.GetTournamentRole(tournamentID, targetID)
+	if err != nil || targetRole == "" {
+		return err == nil, err
+	}
+
+	actorRole, err := database.GetTournamentRole(tournamentID, actorID)
+	if err != nil {
+		return false, err
+	}
+	if actorRole == RoleOwner {
+		return true, nil
+	}
+	return IsAdmin(actorID)
+}
+
+// RequireAdmin restringe la ruta a administradores. Debe ir después de AuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, err := IsAdmin(c.GetInt("user_id"))
+		if err != nil || !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Se requieren permisos de administrador"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireTournamentPermission restringe la ruta a quien pueda realizar la acción sobre
+// el torneo del parámetro :id. Debe ir después de AuthMiddleware.
+func RequireTournamentPermission(action Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		ok, err := CanTournament(c.GetInt("user_id"), tournamentID, action)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Torneo no encontrado"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error al comprobar los permisos"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No tienes permisos para realizar esta acción en el torneo"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CanViewUserSection comprueba si viewerID (0 = sin sesión) puede ver una sección del
+// perfil de owner con la visibilidad indicada. Los administradores lo ven todo.
+func CanViewUserSection(viewerID int, owner *models.User, level string) bool {
+	if models.Allows(level, viewerID, owner.ID) {
+		return true
+	}
+	if viewerID == 0 {
+		return false
+	}
+	admin, err := IsAdmin(viewerID)
+	return err == nil && admin
+}
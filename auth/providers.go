@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"torneos/models"
+)
+
+// envOr devuelve la variable de entorno o el valor por defecto si no está definida
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// LoadProviders registra los proveedores configurados en el entorno. Cada proveedor
+// se activa con su <PROVEEDOR>_CLIENT_ID (STEAM_REDIRECT_URI en Steam) y sus endpoints
+// pueden sustituirse con <PROVEEDOR>_AUTH_URL, _TOKEN_URL y _USERINFO_URL.
+func LoadProviders() {
+	if os.Getenv("DISCORD_CLIENT_ID") != "" {
+		RegisterProvider(NewDiscordProvider())
+	}
+	if os.Getenv("TWITCH_CLIENT_ID") != "" {
+		RegisterProvider(NewTwitchProvider())
+	}
+	if os.Getenv("GOOGLE_CLIENT_ID") != "" {
+		RegisterProvider(NewGoogleProvider())
+	}
+	if os.Getenv("STEAM_REDIRECT_URI") != "" {
+		RegisterProvider(NewSteamProvider())
+	}
+}
+
+func NewDiscordProvider() *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName:     "discord",
+		ClientID:         os.Getenv("DISCORD_CLIENT_ID"),
+		ClientSecret:     os.Getenv("DISCORD_CLIENT_SECRET"),
+		RedirectURL:      os.Getenv("DISCORD_REDIRECT_URI"),
+		Scopes:           []string{"identify"},
+		PKCE:             true,
+		AuthEndpoint:     envOr("DISCORD_AUTH_URL", "https://discord.com/oauth2/authorize"),
+		TokenEndpoint:    envOr("DISCORD_TOKEN_URL", "https://discord.com/api/oauth2/token"),
+		UserInfoEndpoint: envOr("DISCORD_USERINFO_URL", "https://discord.com/api/users/@me"),
+		ParseIdentity: func(body []byte) (*models.UserIdentity, error) {
+			var u struct {
+				ID       string `json:"id"`
+				Username string `json:"username"`
+				Avatar   string `json:"avatar"`
+				Email    string `json:"email"`
+			}
+			if err := json.Unmarshal(body, &u); err != nil {
+				return nil, errors.New("perfil de Discord inválido")
+			}
+
+			identity := &models.UserIdentity{ProviderUserID: u.ID, Username: u.Username, Email: u.Email}
+			if u.Avatar != "" {
+				identity.AvatarURL = "https://cdn.discordapp.com/avatars/" + u.ID + "/" + u.Avatar + ".png"
+			}
+			return identity, nil
+		},
+	}
+}
+
+func NewTwitchProvider() *OAuth2Provider {
+	clientID := os.Getenv("TWITCH_CLIENT_ID")
+	return &OAuth2Provider{
+		ProviderName:     "twitch",
+		ClientID:         clientID,
+		ClientSecret:     os.Getenv("TWITCH_CLIENT_SECRET"),
+		RedirectURL:      os.Getenv("TWITCH_REDIRECT_URI"),
+		Scopes:           []string{"user:read:email"},
+		AuthEndpoint:     envOr("TWITCH_AUTH_URL", "https://id.twitch.tv/oauth2/authorize"),
+		TokenEndpoint:    envOr("TWITCH_TOKEN_URL", "https://id.twitch.tv/oauth2/token"),
+		UserInfoEndpoint: envOr("TWITCH_USERINFO_URL", "https://api.twitch.tv/helix/users"),
+		UserInfoHeaders:  map[string]string{"Client-Id": clientID},
+		ParseIdentity: func(body []byte) (*models.UserIdentity, error) {
+			var res struct {
+				Data []struct {
+					ID              string `json:"id"`
+					Login           string `json:"login"`
+					DisplayName     string `json:"display_name"`
+					ProfileImageURL string `json:"profile_image_url"`
+					Email           string `json:"email"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(body, &res); err != nil || len(res.Data) == 0 {
+				return nil, errors.New("perfil de Twitch inválido")
+			}
+
+			u := res.Data[0]
+			username := u.DisplayName
+			if username == "" {
+				username = u.Login
+			}
+			return &models.UserIdentity{
+				ProviderUserID: u.ID,
+				Username:       username,
+				Email:          u.Email,
+				AvatarURL:      u.ProfileImageURL,
+			}, nil
+		},
+	}
+}
+
+func NewGoogleProvider() *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName:     "google",
+		ClientID:         os.Getenv("GOOGLE_CLIENT_ID"),
+		ClientSecret:     os.Getenv("GOOGLE_CLIENT_SECRET"),
+		RedirectURL:      os.Getenv("GOOGLE_REDIRECT_URI"),
+		Scopes:           []string{"openid", "email", "profile"},
+		PKCE:             true,
+		AuthEndpoint:     envOr("GOOGLE_AUTH_URL", "https://accounts.google.com/o/oauth2/v2/auth"),
+		TokenEndpoint:    envOr("GOOGLE_TOKEN_URL", "https://oauth2.googleapis.com/token"),
+		UserInfoEndpoint: envOr("GOOGLE_USERINFO_URL", "https://openidconnect.googleapis.com/v1/userinfo"),
+		ParseIdentity: func(body []byte) (*models.UserIdentity, error) {
+			var u struct {
+				Sub     string `json:"sub"`
+				Name    string `json:"name"`
+				Email   string `json:"email"`
+				Picture string `json:"picture"`
+			}
+			if err := json.Unmarshal(body, &u); err != nil {
+				return nil, errors.New("perfil de Google inválido")
+			}
+			return &models.UserIdentity{
+				ProviderUserID: u.Sub,
+				Username:       u.Name,
+				Email:          u.Email,
+				AvatarURL:      u.Picture,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var ErrRedirectNotAllowed = errors.New("redirect_to no permitido")
+
+// RedirectOrigins devuelve los orígenes a los que se puede volver tras iniciar sesión:
+// los indicados y los de AUTH_REDIRECT_ORIGINS, separados por comas
+func RedirectOrigins(defaults ...string) []string {
+	origins := []string{}
+	for _, o := range append(defaults, strings.Split(os.Getenv("AUTH_REDIRECT_ORIGINS"), ",")...) {
+		if o = strings.TrimSuffix(strings.TrimSpace(o), "/"); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// ValidateRedirect acepta una ruta relativa ("/tournaments/3") o una URL absoluta de
+// uno de los orígenes permitidos, y devuelve el origen y la ruta por separado. Una
+// ruta relativa se resuelve contra el primer origen.
+func ValidateRedirect(raw string, origins []string) (origin, path string, err error) {
+	if len(origins) == 0 {
+		return "", "", ErrRedirectNotAllowed
+	}
+	if raw == "" {
+		return origins[0], "", nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil || strings.Contains(raw, `\`) {
+		return "", "", ErrRedirectNotAllowed
+	}
+
+	origin = origins[0]
+	if u.Scheme != "" || u.Host != "" {
+		origin = ""
+		for _, o := range origins {
+			if strings.EqualFold(u.Scheme+"://"+u.Host, o) {
+				origin = o
+				break
+			}
+		}
+		if origin == "" {
+			return "", "", ErrRedirectNotAllowed
+		}
+	}
+
+	// "//evil.com" es una URL absoluta sin esquema: solo se aceptan rutas propias
+	if u.Path != "" && (!strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//")) {
+		return "", "", ErrRedirectNotAllowed
+	}
+
+	path = u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		path += "#" + u.EscapedFragment()
+	}
+	return origin, path, nil
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateRedirect(t *testing.T) {
+	origins := []string{"https://app.example.com", "http://localhost:3000"}
+
+	tests := []struct {
+		name       string
+		raw        string
+		wantOrigin string
+		wantPath   string
+		wantErr    bool
+	}{
+		{name: "vacío", raw: "", wantOrigin: "https://app.example.com"},
+		{name: "ruta relativa", raw: "/tournaments/3", wantOrigin: "https://app.example.com", wantPath: "/tournaments/3"},
+		{name: "query y fragmento", raw: "/tournaments/3?tab=bracket#final", wantOrigin: "https://app.example.com", wantPath: "/tournaments/3?tab=bracket#final"},
+		{name: "absoluta permitida", raw: "http://localhost:3000/profile", wantOrigin: "http://localhost:3000", wantPath: "/profile"},
+		{name: "absoluta sin ruta", raw: "https://app.example.com", wantOrigin: "https://app.example.com"},
+		{name: "absoluta en mayúsculas", raw: "HTTPS://APP.EXAMPLE.COM/x", wantOrigin: "https://app.example.com", wantPath: "/x"},
+		{name: "barra invertida codificada", raw: "/%5Cevil.com", wantOrigin: "https://app.example.com", wantPath: "/%5Cevil.com"},
+
+		{name: "protocolo relativo", raw: "//evil.com", wantErr: true},
+		{name: "protocolo relativo con ruta", raw: "//evil.com/tournaments", wantErr: true},
+		{name: "barras codificadas", raw: "/%2F%2Fevil.com", wantErr: true},
+		{name: "barras codificadas sin barra inicial", raw: "%2F%2Fevil.com", wantErr: true},
+		{name: "barra invertida", raw: `/\evil.com`, wantErr: true},
+		{name: "barras invertidas", raw: `\\evil.com`, wantErr: true},
+		{name: "userinfo", raw: "https://app.example.com@evil.com", wantErr: true},
+		{name: "userinfo con origen permitido", raw: "https://evil@app.example.com/x", wantErr: true},
+		{name: "absoluta no permitida", raw: "https://evil.com/x", wantErr: true},
+		{name: "subdominio falso", raw: "https://app.example.com.evil.com/x", wantErr: true},
+		{name: "otro esquema", raw: "http://app.example.com/x", wantErr: true},
+		{name: "javascript", raw: "javascript:alert(1)", wantErr: true},
+		{name: "ruta sin barra", raw: "evil.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin, path, err := ValidateRedirect(tt.raw, origins)
+			if tt.wantErr {
+				if !errors.Is(err, ErrRedirectNotAllowed) {
+					t.Fatalf("ValidateRedirect(%q) = %q, %q, %v; se esperaba ErrRedirectNotAllowed", tt.raw, origin, path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateRedirect(%q): %v", tt.raw, err)
+			}
+			if origin != tt.wantOrigin || path != tt.wantPath {
+				t.Fatalf("ValidateRedirect(%q) = %q, %q; se esperaba %q, %q", tt.raw, origin, path, tt.wantOrigin, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestValidateRedirectWithoutOrigins(t *testing.T) {
+	if _, _, err := ValidateRedirect("/tournaments", nil); !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatalf("sin orígenes se esperaba ErrRedirectNotAllowed, se obtuvo %v", err)
+	}
+}
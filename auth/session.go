@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"torneos/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	// El refresh token solo se envía a /auth (refresh, logout), nunca al resto de la API
+	RefreshCookieName = "refresh_token"
+	refreshCookiePath = "/auth"
+)
+
+// IssueSession abre una sesión nueva tras iniciar sesión: guarda la cookie con el
+// refresh token y devuelve un token de acceso
+func IssueSession(c *gin.Context, userID int) (string, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.CreateRefreshToken(database.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refresh),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		TTL:       RefreshTokenTTL,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	setCookie(c, RefreshCookieName, refresh, refreshCookiePath, int(RefreshTokenTTL.Seconds()))
+	return GenerateJWT(userID)
+}
+
+// RefreshSession rota el refresh token de la cookie y devuelve un token de acceso nuevo
+func RefreshSession(c *gin.Context) (string, error) {
+	current, err := c.Cookie(RefreshCookieName)
+	if err != nil || current == "" {
+		return "", database.ErrRefreshTokenInvalid
+	}
+
+	next, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := database.RotateRefreshToken(hashToken(current), database.RefreshToken{
+		TokenHash: hashToken(next),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		TTL:       RefreshTokenTTL,
+	})
+	if errors.Is(err, database.ErrRefreshTokenReused) {
+		log.Printf("Reutilización de refresh token del usuario %d desde %s: sesión revocada", userID, c.ClientIP())
+	}
+	if err != nil {
+		clearRefreshCookie(c)
+		return "", err
+	}
+
+	if err := database.CheckUserActive(userID, time.Now()); err != nil {
+		database.RevokeRefreshFamily(hashToken(next))
+		clearRefreshCookie(c)
+		return "", err
+	}
+
+	setCookie(c, RefreshCookieName, next, refreshCookiePath, int(RefreshTokenTTL.Seconds()))
+	return GenerateJWT(userID)
+}
+
+// EndSession cierra la sesión de la cookie. No falla si no hay sesión.
+func EndSession(c *gin.Context) error {
+	defer clearRefreshCookie(c)
+
+	current, err := c.Cookie(RefreshCookieName)
+	if err != nil || current == "" {
+		return nil
+	}
+	return database.RevokeRefreshFamily(hashToken(current))
+}
+
+func clearRefreshCookie(c *gin.Context) {
+	setCookie(c, RefreshCookieName, "", refreshCookiePath, -1)
+}
+
+// StartSessionCleanup borra periódicamente los refresh tokens caducados
+func StartSessionCleanup() {
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := database.DeleteExpiredRefreshTokens(); err != nil {
+				log.Printf("Error borrando refresh tokens caducados: %v", err)
+			}
+		}
+	}()
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
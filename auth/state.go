@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Cookie con el estado del inicio de sesión entre /login y /callback
+	StateCookieName = "oauth_state"
+	stateTTL        = 10 * time.Minute
+)
+
+var ErrInvalidState = errors.New("state inválido o caducado")
+
+// LoginState es lo que se guarda, firmado, en la cookie de estado. State viaja también
+// en la URL del proveedor y debe volver igual en el callback; CodeVerifier es el secreto
+// PKCE: al proveedor solo le llega su hash hasta que se canjea el código.
+type LoginState struct {
+	Provider     string
+	State        string
+	CodeVerifier string
+	// Usuario que está vinculando un proveedor más (0 en un inicio de sesión normal)
+	LinkUserID int
+	// Ruta del frontend a la que volver tras iniciar sesión, ya validada
+	RedirectTo string
+}
+
+func NewLoginState(provider string) (*LoginState, error) {
+	state, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken(48)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginState{Provider: provider, State: state, CodeVerifier: verifier}, nil
+}
+
+// CodeChallenge es el reto PKCE S256 del verifier
+func (s *LoginState) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(s.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// SetCookie firma el estado y lo guarda en una cookie httpOnly que solo se envía a /auth
+func (s *LoginState) SetCookie(c *gin.Context) error {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return jwt.ErrTokenMalformed
+	}
+
+	claims := jwt.MapClaims{
+		"purpose":       "oauth_state",
+		"provider":      s.Provider,
+		"state":         s.State,
+		"code_verifier": s.CodeVerifier,
+		"link_user_id":  s.LinkUserID,
+		"redirect_to":   s.RedirectTo,
+		"exp":           time.Now().Add(stateTTL).Unix(),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return err
+	}
+
+	setCookie(c, StateCookieName, signed, "/auth", int(stateTTL.Seconds()))
+	return nil
+}
+
+// ConsumeLoginState lee y borra la cookie de estado y comprueba que corresponde al
+// proveedor y al state con los que vuelve el callback. Así un callback que no haya
+// empezado en este navegador (CSRF, fijación de sesión) se rechaza.
+func ConsumeLoginState(c *gin.Context, provider string) (*LoginState, error) {
+	raw, err := c.Cookie(StateCookieName)
+	setCookie(c, StateCookieName, "", "/auth", -1)
+	if err != nil || raw == "" {
+		return nil, ErrInvalidState
+	}
+
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidState
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != "oauth_state" {
+		return nil, ErrInvalidState
+	}
+
+	s := &LoginState{}
+	s.Provider, _ = claims["provider"].(string)
+	s.State, _ = claims["state"].(string)
+	s.CodeVerifier, _ = claims["code_verifier"].(string)
+	s.RedirectTo, _ = claims["redirect_to"].(string)
+	if id, ok := claims["link_user_id"].(float64); ok {
+		s.LinkUserID = int(id)
+	}
+
+	received := c.Query("state")
+	if s.Provider != provider || s.State == "" ||
+		subtle.ConstantTimeCompare([]byte(s.State), []byte(received)) != 1 {
+		return nil, ErrInvalidState
+	}
+	return s, nil
+}
+
+func setCookie(c *gin.Context, name, value, path string, maxAge int) {
+	sameSite := cookieSameSite()
+	c.SetSameSite(sameSite)
+	c.SetCookie(name, value, maxAge, path, "", sameSite == http.SameSiteNoneMode || secureCookies(c), true)
+}
+
+// cookieSameSite devuelve el modo SameSite de las cookies de sesión y de estado
+// (COOKIE_SAMESITE=lax|strict|none, lax por defecto). Si el frontend y la API están en
+// sitios distintos (Vercel y Render) el navegador no envía cookies Lax en los fetch del
+// frontend a /auth: hay que usar none, que exige HTTPS.
+func cookieSameSite() http.SameSite {
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "none":
+		return http.SameSiteNoneMode
+	case "strict":
+		return http.SameSiteStrictMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// secureCookies marca las cookies como Secure cuando la petición llega por HTTPS,
+// directamente o a través de un proxy
+func secureCookies(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
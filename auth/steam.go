@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"torneos/models"
+)
+
+const openIDNamespace = "http://specs.openid.net/auth/2.0"
+
+// SteamProvider inicia sesión con Steam mediante OpenID 2.0. Steam no usa OAuth2: el
+// proveedor vuelve con una aserción firmada que se valida con check_authentication.
+type SteamProvider struct {
+	// URL de retorno (/auth/steam/callback) y realm (origen del backend)
+	ReturnURL string
+	Realm     string
+
+	OpenIDEndpoint string
+	// Prefijo del claimed_id que devuelve Steam, seguido del SteamID64
+	ClaimedIDPrefix string
+
+	// Con APIKey se completan el nombre y el avatar con la Web API de Steam
+	APIKey string
+	APIURL string
+}
+
+var steamIDPattern = regexp.MustCompile(`^[0-9]{17}$`)
+
+func NewSteamProvider() *SteamProvider {
+	returnURL := os.Getenv("STEAM_REDIRECT_URI")
+	realm := os.Getenv("STEAM_REALM")
+	if realm == "" {
+		if u, err := url.Parse(returnURL); err == nil {
+			realm = u.Scheme + "://" + u.Host
+		}
+	}
+
+	return &SteamProvider{
+		ReturnURL:       returnURL,
+		Realm:           realm,
+		OpenIDEndpoint:  envOr("STEAM_OPENID_URL", "https://steamcommunity.com/openid/login"),
+		ClaimedIDPrefix: envOr("STEAM_CLAIMED_ID_PREFIX", "https://steamcommunity.com/openid/id/"),
+		APIKey:          os.Getenv("STEAM_API_KEY"),
+		APIURL:          envOr("STEAM_API_URL", "https://api.steampowered.com"),
+	}
+}
+
+func (p *SteamProvider) Name() string { return "steam" }
+
+func (p *SteamProvider) AuthURL(state, _ string) string {
+	returnTo := p.ReturnURL
+	if state != "" {
+		returnTo += "?state=" + url.QueryEscape(state)
+	}
+
+	q := url.Values{}
+	q.Set("openid.ns", openIDNamespace)
+	q.Set("openid.mode", "checkid_setup")
+	q.Set("openid.return_to", returnTo)
+	q.Set("openid.realm", p.Realm)
+	q.Set("openid.identity", openIDNamespace+"/identifier_select")
+	q.Set("openid.claimed_id", openIDNamespace+"/identifier_select")
+	return p.OpenIDEndpoint + "?" + q.Encode()
+}
+
+// Callback valida la aserción OpenID. Steam no admite PKCE: el state viaja dentro de
+// openid.return_to, que forma parte de lo firmado.
+func (p *SteamProvider) Callback(ctx context.Context, params url.Values, _ string) (*models.UserIdentity, error) {
+	switch params.Get("openid.mode") {
+	case "id_res":
+	case "cancel":
+		return nil, errors.New("inicio de sesión con Steam cancelado")
+	default:
+		return nil, errors.New("respuesta de Steam inválida")
+	}
+	if !strings.HasPrefix(params.Get("openid.return_to"), p.ReturnURL) {
+		return nil, errors.New("openid.return_to no coincide")
+	}
+
+	steamID := strings.TrimPrefix(params.Get("openid.claimed_id"), p.ClaimedIDPrefix)
+	if !steamIDPattern.MatchString(steamID) {
+		return nil, errors.New("claimed_id de Steam inválido")
+	}
+
+	if err := p.verify(ctx, params); err != nil {
+		return nil, err
+	}
+
+	identity := &models.UserIdentity{Provider: "steam", ProviderUserID: steamID, Username: "steam_" + steamID}
+	if p.APIKey != "" {
+		p.fillProfile(ctx, identity)
+	}
+	return identity, nil
+}
+
+// verify pide a Steam que confirme la firma de la aserción
+func (p *SteamProvider) verify(ctx context.Context, params url.Values) error {
+	form := url.Values{}
+	for k, v := range params {
+		if strings.HasPrefix(k, "openid.") {
+			form[k] = v
+		}
+	}
+	form.Set("openid.mode", "check_authentication")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.OpenIDEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := doOAuthRequest(req)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.TrimSpace(line) == "is_valid:true" {
+			return nil
+		}
+	}
+	return errors.New("Steam no ha validado la aserción")
+}
+
+// fillProfile completa nombre y avatar; si la Web API falla se mantienen los valores por defecto
+func (p *SteamProvider) fillProfile(ctx context.Context, identity *models.UserIdentity) {
+	q := url.Values{}
+	q.Set("key", p.APIKey)
+	q.Set("steamids", identity.ProviderUserID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		p.APIURL+"/ISteamUser/GetPlayerSummaries/v0002/?"+q.Encode(), nil)
+	if err != nil {
+		return
+	}
+
+	body, err := doOAuthRequest(req)
+	if err != nil {
+		return
+	}
+
+	var res struct {
+		Response struct {
+			Players []struct {
+				PersonaName string `json:"personaname"`
+				AvatarFull  string `json:"avatarfull"`
+			} `json:"players"`
+		} `json:"response"`
+	}
+	if json.Unmarshal(body, &res) != nil || len(res.Response.Players) == 0 {
+		return
+	}
+
+	player := res.Response.Players[0]
+	if player.PersonaName != "" {
+		identity.Username = player.PersonaName
+	}
+	identity.AvatarURL = player.AvatarFull
+}
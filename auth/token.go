@@ -7,6 +7,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// GenerateJWT emite un token de acceso de corta duración; se renueva con /auth/refresh
 func GenerateJWT(userID int) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
@@ -15,7 +16,7 @@ func GenerateJWT(userID int) (string, error) {
 
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(), // expira en 24h
+		"exp":     time.Now().Add(AccessTokenTTL).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
@@ -0,0 +1,33 @@
+// Comando para evaluar las reglas de logros sobre el histórico de torneos y matches.
+// Se ejecuta desde la raíz del proyecto: go run ./cmd/backfill-achievements
+package main
+
+import (
+	"log"
+
+	"torneos/database"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No se pudo cargar .env (probablemente en producción)")
+	}
+
+	if err := database.ConnectDatabase(); err != nil {
+		log.Fatalf("Error conectando a la base de datos: %v", err)
+	}
+	defer database.CloseDatabase()
+
+	if err := database.RunMigrations(); err != nil {
+		log.Fatalf("Error aplicando migración: %v", err)
+	}
+
+	processed, err := database.BackfillAchievements()
+	if err != nil {
+		log.Fatalf("Error en el backfill de logros: %v", err)
+	}
+
+	log.Printf("Logros recalculados para %d usuarios", processed)
+}
@@ -0,0 +1,34 @@
+// Comando para registrar en Discord los comandos de barra (/join, /report...).
+// Necesita DISCORD_CLIENT_ID y DISCORD_BOT_TOKEN: go run ./cmd/register-discord-commands
+package main
+
+import (
+	"log"
+	"os"
+
+	"torneos/discord"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No se pudo cargar .env (probablemente en producción)")
+	}
+
+	apiURL := os.Getenv("DISCORD_API_URL")
+	if apiURL == "" {
+		apiURL = "https://discord.com/api/v10"
+	}
+
+	appID, token := os.Getenv("DISCORD_CLIENT_ID"), os.Getenv("DISCORD_BOT_TOKEN")
+	if appID == "" || token == "" {
+		log.Fatal("Faltan DISCORD_CLIENT_ID o DISCORD_BOT_TOKEN")
+	}
+
+	if err := discord.RegisterCommands(apiURL, appID, token); err != nil {
+		log.Fatalf("Error registrando los comandos: %v", err)
+	}
+
+	log.Printf("%d comandos registrados", len(discord.Commands))
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"torneos/models"
+)
+
+// ExportUserData reúne el perfil y toda la actividad del usuario para su exportación
+func ExportUserData(userID int) (*models.UserExport, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	role, err := GetUserRole(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    models.NewPrivateUser(user, role),
+	}
+
+	if export.Identities, err = GetUserIdentities(userID); err != nil {
+		return nil, err
+	}
+	if export.Achievements, err = GetUserAchievements(userID); err != nil {
+		return nil, err
+	}
+	if export.Participations, err = GetUserTournamentHistory(userID); err != nil {
+		return nil, err
+	}
+	if export.Matches, err = GetUserMatches(userID); err != nil {
+		return nil, err
+	}
+	if err := DB.QueryRow(context.Background(),
+		`SELECT COALESCE(points, 0) FROM users WHERE id = $1`, userID).Scan(&export.Points); err != nil {
+		return nil, err
+	}
+	if export.PointsHistory, err = GetUserPointsHistory(userID); err != nil {
+		return nil, err
+	}
+	if export.Screenshots, err = GetUserScreenshots(userID); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// GetUserPointsHistory desglosa por torneo los puntos de ranking del usuario (ver
+// pointAwardsCTE)
+func GetUserPointsHistory(userID int) ([]models.PointsEntry, error) {
+	rows, err := DB.Query(context.Background(), `
+        WITH `+pointAwardsCTE+`
+        SELECT t.id, t.name, t.game, t.start_time, SUM(a.points)::int
+        FROM point_awards a
+        JOIN tournaments t ON t.id = a.tournament_id
+        WHERE a.user_id = $1
+        GROUP BY t.id
+        ORDER BY t.start_time DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.PointsEntry{}
+	for rows.Next() {
+		var e models.PointsEntry
+		if err := rows.Scan(&e.TournamentID, &e.Name, &e.Game, &e.StartTime, &e.Points); err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	return history, rows.Err()
+}
+
+// GetUserScreenshots devuelve las capturas subidas por el usuario. Las anteriores a que
+// se guardara quién las subió se atribuyen a los jugadores del match.
+func GetUserScreenshots(userID int) ([]models.Screenshot, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT id, tournament_id, screenshot_url
+        FROM matches
+        WHERE screenshot_url IS NOT NULL
+          AND (screenshot_uploaded_by = $1
+               OR (screenshot_uploaded_by IS NULL AND $1 IN (player1_id, player2_id)))
+        ORDER BY id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	screenshots := []models.Screenshot{}
+	for rows.Next() {
+		var s models.Screenshot
+		if err := rows.Scan(&s.MatchID, &s.TournamentID, &s.URL); err != nil {
+			return nil, err
+		}
+		screenshots = append(screenshots, s)
+	}
+	return screenshots, rows.Err()
+}
+
+// ErrOwnsActiveTournaments indica que el usuario aún es dueño de torneos sin finalizar
+var ErrOwnsActiveTournaments = errors.New("no puedes borrar la cuenta mientras organices torneos sin finalizar; finalízalos o elimínalos primero")
+
+// AnonymizeUser borra la cuenta del usuario. La fila se conserva, sin datos personales y
+// con el nombre models.DeletedUsername, para que sus matches, inscripciones y
+// clasificaciones sigan siendo válidos para los demás jugadores. Se cierran sus sesiones
+// y se eliminan sus cuentas vinculadas, tokens, notificaciones y cargos de staff, además
+// de las inscripciones en torneos que aún no han empezado y sus IPs de la auditoría.
+// No se permite mientras sea dueño de torneos sin finalizar, que se quedarían sin nadie
+// que los gestione; en los finalizados se desactivan sus webhooks.
+func AnonymizeUser(userID int) error {
+	ctx := context.Background()
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var ownsActive bool
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM tournaments
+            WHERE created_by_user_id = $1 AND NOT COALESCE(is_finished, FALSE)
+        )
+    `, userID).Scan(&ownsActive)
+	if err != nil {
+		return err
+	}
+	if ownsActive {
+		return ErrOwnsActiveTournaments
+	}
+
+	tag, err := tx.Exec(ctx, `
+        UPDATE users
+        SET username = $2,
+            email = NULL,
+            oauth_provider = NULL,
+            oauth_id = NULL,
+            avatar_url = NULL,
+            twitch = NULL,
+            youtube = NULL,
+            role = 'user',
+            deleted_at = NOW(),
+            sessions_revoked_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL
+    `, userID, models.DeletedUsername)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	for _, query := range []string{
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM refresh_tokens WHERE user_id = $1`,
+		`DELETE FROM api_tokens WHERE user_id = $1`,
+		`DELETE FROM notifications WHERE user_id = $1`,
+		`DELETE FROM notification_preferences WHERE user_id = $1`,
+		`DELETE FROM tournament_staff WHERE user_id = $1`,
+		`DELETE FROM participants p
+         WHERE p.user_id = $1
+           AND NOT EXISTS (SELECT 1 FROM matches m WHERE m.tournament_id = p.tournament_id)`,
+		`UPDATE webhooks SET active = FALSE
+         WHERE tournament_id IN (SELECT id FROM tournaments WHERE created_by_user_id = $1)`,
+		`UPDATE tournaments SET discord_webhook_url = NULL WHERE created_by_user_id = $1`,
+		// La auditoría se conserva, pero sin las IPs del usuario ni los datos de perfil
+		// que se guardaron al cambiarlos
+		`UPDATE audit_log SET ip = NULL WHERE actor_user_id = $1`,
+		`UPDATE audit_log SET before = NULL, after = NULL
+         WHERE target_type = 'user' AND target_id = $1 AND action LIKE 'profile.%'`,
+	} {
+		if _, err := tx.Exec(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"torneos/achievements"
+	"torneos/models"
+	"torneos/realtime"
+)
+
+// loadAchievementFacts calcula los datos históricos de un usuario para evaluar los logros
+func loadAchievementFacts(userID int) (achievements.Facts, error) {
+	f := achievements.Facts{UserID: userID}
+
+	err := DB.QueryRow(context.Background(), `
+        SELECT
+            (SELECT COUNT(*) FROM matches m
+             WHERE m.winner_id = $1 AND `+playedMatchCondition+`),
+            (SELECT COUNT(*) FROM participants WHERE user_id = $1),
+            (SELECT COUNT(*) FROM tournaments WHERE champion_id = $1),
+            (SELECT COUNT(*) FROM tournaments t
+             WHERE t.champion_id = $1
+               AND NOT EXISTS (
+                 SELECT 1 FROM matches m
+                 WHERE m.tournament_id = t.id
+                   AND (m.player1_id = $1 OR m.player2_id = $1)
+                   AND (m.player1_id IS NULL OR m.player2_id IS NULL)
+               ))
+    `, userID).Scan(&f.MatchesWon, &f.TournamentsPlayed, &f.TournamentsWon, &f.FlawlessTournaments)
+
+	return f, err
+}
+
+// unlockAchievements guarda los logros desbloqueados y notifica solo los nuevos
+func unlockAchievements(userID int, tournamentID *int, rules []achievements.Rule) error {
+	for _, r := range rules {
+		tag, err := DB.Exec(context.Background(), `
+            INSERT INTO user_achievements (user_id, code, tournament_id)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (user_id, code) DO NOTHING
+        `, userID, r.Code, tournamentID)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() > 0 {
+			realtime.SendToUser(userID, realtime.NewAchievementUnlockedEvent(userID, tournamentID, r.Code, r.Name, r.Description))
+			Notify(models.Notification{
+				UserID:       userID,
+				Type:         models.NotificationAchievementUnlocked,
+				Title:        "Logro desbloqueado: " + r.Name,
+				Body:         r.Description,
+				TournamentID: tournamentID,
+			})
+		}
+	}
+	return nil
+}
+
+// evaluateMatchAchievements evalúa los logros del ganador de un match recién completado.
+// Debe llamarse antes de avanzar de ronda para comparar los puntos previos a la final.
+func evaluateMatchAchievements(matchID, winnerID, loserID int) {
+	f, err := loadAchievementFacts(winnerID)
+	if err != nil {
+		log.Printf("Error calculando logros del match %d: %v", matchID, err)
+		return
+	}
+
+	if loserID != 0 {
+		var winnerPoints, loserPoints int
+		err := DB.QueryRow(context.Background(), `
+            SELECT
+                (SELECT COALESCE(points, 0) FROM users WHERE id = $1),
+                (SELECT COALESCE(points, 0) FROM users WHERE id = $2)
+        `, winnerID, loserID).Scan(&winnerPoints, &loserPoints)
+		if err != nil {
+			log.Printf("Error calculando logros del match %d: %v", matchID, err)
+			return
+		}
+		f.BeatHigherRated = loserPoints-winnerPoints >= achievements.GiantSlayerMinGap
+	}
+
+	var tournamentID int
+	if err := DB.QueryRow(context.Background(), `SELECT tournament_id FROM matches WHERE id = $1`, matchID).Scan(&tournamentID); err != nil {
+		log.Printf("Error calculando logros del match %d: %v", matchID, err)
+		return
+	}
+
+	if err := unlockAchievements(winnerID, &tournamentID, achievements.Evaluate(achievements.MatchCompleted, f)); err != nil {
+		log.Printf("Error guardando logros del match %d: %v", matchID, err)
+	}
+}
+
+// evaluateTournamentAchievements evalúa los logros de todos los participantes de un torneo finalizado
+func evaluateTournamentAchievements(tournamentID int) {
+	participants, err := GetParticipantsByTournamentID(tournamentID)
+	if err != nil {
+		log.Printf("Error calculando logros del torneo %d: %v", tournamentID, err)
+		return
+	}
+
+	for _, p := range participants {
+		f, err := loadAchievementFacts(p.ID)
+		if err != nil {
+			log.Printf("Error calculando logros del usuario %d: %v", p.ID, err)
+			continue
+		}
+
+		if err := unlockAchievements(p.ID, &tournamentID, achievements.Evaluate(achievements.TournamentFinished, f)); err != nil {
+			log.Printf("Error guardando logros del usuario %d: %v", p.ID, err)
+		}
+	}
+}
+
+// beatHigherRatedHistorically indica si el usuario ganó algún match a un rival con al
+// menos GiantSlayerMinGap puntos más que él en el momento de jugarlo, sumando los
+// puntos de point_awards concedidos antes del match
+func beatHigherRatedHistorically(userID int) (bool, error) {
+	var beat bool
+	err := DB.QueryRow(context.Background(), `
+        WITH `+pointAwardsCTE+`
+        SELECT EXISTS (
+            SELECT 1 FROM matches m
+            WHERE m.winner_id = $1 AND m.played_at IS NOT NULL AND `+playedMatchCondition+`
+              AND (SELECT COALESCE(SUM(a.points), 0) FROM point_awards a
+                   WHERE a.user_id = CASE WHEN m.player1_id = $1 THEN m.player2_id ELSE m.player1_id END
+                     AND a.awarded_at < m.played_at)
+                - (SELECT COALESCE(SUM(a.points), 0) FROM point_awards a
+                   WHERE a.user_id = $1 AND a.awarded_at < m.played_at) >= $2
+        )
+    `, userID, achievements.GiantSlayerMinGap).Scan(&beat)
+	return beat, err
+}
+
+// BackfillAchievements evalúa todas las reglas sobre el histórico de cada usuario.
+// Devuelve el número de usuarios procesados.
+func BackfillAchievements() (int, error) {
+	rows, err := DB.Query(context.Background(), `SELECT id FROM users ORDER BY id`)
+	if err != nil {
+		return 0, err
+	}
+
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+
+	for _, id := range userIDs {
+		f, err := loadAchievementFacts(id)
+		if err == nil {
+			f.BeatHigherRated, err = beatHigherRatedHistorically(id)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error calculando logros del usuario %d: %w", id, err)
+		}
+
+		if err := unlockAchievements(id, nil, achievements.EvaluateAll(f)); err != nil {
+			return 0, fmt.Errorf("error guardando logros del usuario %d: %w", id, err)
+		}
+	}
+
+	return len(userIDs), nil
+}
+
+func GetUserAchievements(userID int) ([]models.Achievement, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT code, tournament_id, unlocked_at
+        FROM user_achievements
+        WHERE user_id = $1
+        ORDER BY unlocked_at, id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Achievement{}
+	for rows.Next() {
+		var a models.Achievement
+		if err := rows.Scan(&a.Code, &a.TournamentID, &a.UnlockedAt); err != nil {
+			return nil, err
+		}
+
+		if rule, ok := achievements.ByCode(a.Code); ok {
+			a.Name = rule.Name
+			a.Description = rule.Description
+		}
+		list = append(list, a)
+	}
+
+	return list, rows.Err()
+}
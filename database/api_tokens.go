@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	"torneos/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrAPITokenInvalid = errors.New("token de API inválido, caducado o revocado")
+
+// CreateAPIToken guarda el token; t.Token no se guarda, solo su hash. ttl 0 = sin caducidad.
+func CreateAPIToken(t *models.APIToken, tokenHash string, ttl time.Duration) error {
+	var expires *string
+	if ttl > 0 {
+		s := ttl.String()
+		expires = &s
+	}
+
+	return DB.QueryRow(context.Background(), `
+        INSERT INTO api_tokens (user_id, name, prefix, token_hash, scopes, expires_at)
+        VALUES ($1, $2, $3, $4, $5, NOW() + $6::interval)
+        RETURNING id, expires_at, created_at
+    `, t.UserID, t.Name, t.Prefix, tokenHash, t.Scopes, expires).Scan(&t.ID, &t.ExpiresAt, &t.CreatedAt)
+}
+
+// GetUserAPITokens devuelve los tokens vigentes del usuario
+func GetUserAPITokens(userID int) ([]models.APIToken, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+        FROM api_tokens
+        WHERE user_id = $1 AND revoked_at IS NULL
+          AND (expires_at IS NULL OR expires_at > NOW())
+        ORDER BY created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var t models.APIToken
+		err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &t.Scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+func RevokeAPIToken(userID, tokenID int) error {
+	tag, err := DB.Exec(context.Background(), `
+        UPDATE api_tokens SET revoked_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+    `, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("token no encontrado")
+	}
+	return nil
+}
+
+// UseAPIToken valida el token con ese hash, anota su último uso y devuelve el usuario
+// y los permisos. El último uso se actualiza como mucho una vez por minuto.
+func UseAPIToken(tokenHash string) (int, []string, error) {
+	var userID int
+	var scopes []string
+	err := DB.QueryRow(context.Background(), `
+        WITH t AS (
+            SELECT id, user_id, scopes, last_used_at FROM api_tokens
+            WHERE token_hash = $1 AND revoked_at IS NULL
+              AND (expires_at IS NULL OR expires_at > NOW())
+        ), touched AS (
+            UPDATE api_tokens SET last_used_at = NOW()
+            FROM t
+            WHERE api_tokens.id = t.id
+              AND (t.last_used_at IS NULL OR t.last_used_at < NOW() - INTERVAL '1 minute')
+        )
+        SELECT user_id, scopes FROM t
+    `, tokenHash).Scan(&userID, &scopes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, ErrAPITokenInvalid
+	}
+	return userID, scopes, err
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"torneos/models"
+)
+
+func CreateAuditEntry(e *models.AuditEntry) error {
+	return DB.QueryRow(context.Background(), `
+        INSERT INTO audit_log (actor_user_id, via_api_token, action, method, path, target_type, target_id,
+                               tournament_id, before, after, ip, status_code)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, NULLIF($11, ''), $12)
+        RETURNING id, created_at
+    `, e.ActorUserID, e.ViaAPIToken, e.Action, e.Method, e.Path, e.TargetType, e.TargetID,
+		e.TournamentID, nullJSON(e.Before), nullJSON(e.After), e.IP, e.StatusCode).Scan(&e.ID, &e.CreatedAt)
+}
+
+func nullJSON(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+// AuditFilter agrupa los filtros y la paginación del registro de auditoría
+type AuditFilter struct {
+	ActorUserID  int
+	TournamentID int
+	Action       string
+	TargetType   string
+	TargetID     int
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	Offset       int
+}
+
+// GetAuditLog devuelve las entradas que cumplen el filtro, las más recientes primero,
+// y el total
+func GetAuditLog(f AuditFilter) ([]models.AuditEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.ActorUserID != 0 {
+		add("a.actor_user_id = $%d", f.ActorUserID)
+	}
+	if f.TournamentID != 0 {
+		add("a.tournament_id = $%d", f.TournamentID)
+	}
+	if f.Action != "" {
+		add("a.action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("a.target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		add("a.target_id = $%d", f.TargetID)
+	}
+	if f.From != nil {
+		add("a.created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("a.created_at < $%d", *f.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := DB.Query(context.Background(), `
+        SELECT a.id, a.actor_user_id, COALESCE(u.username, ''), a.via_api_token, a.action, a.method, a.path,
+               COALESCE(a.target_type, ''), a.target_id, a.tournament_id, a.before, a.after,
+               COALESCE(a.ip, ''), a.status_code, a.created_at, COUNT(*) OVER ()
+        FROM audit_log a
+        LEFT JOIN users u ON u.id = a.actor_user_id
+        `+where+`
+        ORDER BY a.created_at DESC, a.id DESC
+        LIMIT $`+fmt.Sprint(len(args)-1)+` OFFSET $`+fmt.Sprint(len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	total := 0
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.ActorUserID, &e.ActorName, &e.ViaAPIToken, &e.Action, &e.Method, &e.Path,
+			&e.TargetType, &e.TargetID, &e.TournamentID, &before, &after,
+			&e.IP, &e.StatusCode, &e.CreatedAt, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+
+	return entries, total, rows.Err()
+}
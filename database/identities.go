@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"torneos/models"
+)
+
+var ErrIdentityTaken = errors.New("esa cuenta ya está vinculada a otro usuario")
+
+// FindUserByIdentity devuelve el usuario vinculado a la cuenta externa
+func FindUserByIdentity(provider, providerUserID string) (*models.User, error) {
+	var u models.User
+	var email, oauthProvider, oauthID, avatarURL *string
+	err := DB.QueryRow(context.Background(), `
+        SELECT u.id, u.username, u.email, u.oauth_provider, u.oauth_id, u.avatar_url, u.created_at
+        FROM user_identities i
+        JOIN users u ON u.id = i.user_id
+        WHERE i.provider = $1 AND i.provider_user_id = $2
+    `, provider, providerUserID).Scan(&u.ID, &u.Username, &email, &oauthProvider, &oauthID, &avatarURL, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Email = derefString(email)
+	u.OAuthProvider = derefString(oauthProvider)
+	u.OAuthID = derefString(oauthID)
+	u.AvatarURL = derefString(avatarURL)
+	return &u, nil
+}
+
+// CreateUserWithIdentity da de alta un usuario nuevo a partir de su primera cuenta externa
+func CreateUserWithIdentity(identity models.UserIdentity) (*models.User, error) {
+	tx, err := DB.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	u := models.User{
+		Username:      identity.Username,
+		Email:         identity.Email,
+		OAuthProvider: identity.Provider,
+		OAuthID:       identity.ProviderUserID,
+		AvatarURL:     identity.AvatarURL,
+	}
+	err = tx.QueryRow(context.Background(), `
+        INSERT INTO users (username, email, oauth_provider, oauth_id, avatar_url)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `, u.Username, u.Email, u.OAuthProvider, u.OAuthID, u.AvatarURL).Scan(&u.ID, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(context.Background(), `
+        INSERT INTO user_identities (user_id, provider, provider_user_id, username, email, avatar_url)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, u.ID, identity.Provider, identity.ProviderUserID, identity.Username, identity.Email, identity.AvatarURL)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrIdentityTaken
+		}
+		return nil, err
+	}
+
+	return &u, tx.Commit(context.Background())
+}
+
+// LinkIdentity vincula una cuenta externa a un usuario existente. Si el usuario ya
+// tenía una cuenta de ese proveedor se sustituye.
+func LinkIdentity(userID int, identity models.UserIdentity) error {
+	_, err := DB.Exec(context.Background(), `
+        INSERT INTO user_identities (user_id, provider, provider_user_id, username, email, avatar_url)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (user_id, provider) DO UPDATE
+        SET provider_user_id = EXCLUDED.provider_user_id,
+            username = EXCLUDED.username,
+            email = EXCLUDED.email,
+            avatar_url = EXCLUDED.avatar_url,
+            created_at = NOW()
+    `, userID, identity.Provider, identity.ProviderUserID, identity.Username, identity.Email, identity.AvatarURL)
+	if isUniqueViolation(err) {
+		return ErrIdentityTaken
+	}
+	return err
+}
+
+func GetUserIdentities(userID int) ([]models.UserIdentity, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT provider, provider_user_id, COALESCE(username, ''), COALESCE(email, ''),
+               COALESCE(avatar_url, ''), created_at
+        FROM user_identities
+        WHERE user_id = $1
+        ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		var i models.UserIdentity
+		if err := rows.Scan(&i.Provider, &i.ProviderUserID, &i.Username, &i.Email, &i.AvatarURL, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+
+	return identities, rows.Err()
+}
+
+// UnlinkIdentity desvincula un proveedor. No se permite quitar el último, porque el
+// usuario se quedaría sin forma de iniciar sesión.
+func UnlinkIdentity(userID int, provider string) error {
+	tag, err := DB.Exec(context.Background(), `
+        DELETE FROM user_identities
+        WHERE user_id = $1 AND provider = $2
+          AND (SELECT COUNT(*) FROM user_identities WHERE user_id = $1) > 1
+    `, userID, provider)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("no se puede desvincular: el proveedor no está vinculado o es el único")
+	}
+	return nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,8 +14,12 @@ import (
 	"torneos/realtime"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
+// ErrMatchAlreadyReported indica que el match ya tenía un resultado reportado
+var ErrMatchAlreadyReported = errors.New("el resultado ya fue reportado")
+
 func InsertMatch(m *models.Match) (*models.Match, error) {
 	query := `
         INSERT INTO matches (tournament_id, round, player1_id, player2_id, status)
@@ -88,32 +93,32 @@ func ReportMatchResult(matchID, reporterID, winnerID int) error {
 	}
 
 	if status != "pending" {
-		return errors.New("el resultado ya fue reportado")
-	}
-
-	// 2. Verificar si el reportero es jugador o creador del torneo
-	var createdBy int
-	err = DB.QueryRow(context.Background(), `
-		SELECT created_by_user_id FROM tournaments WHERE id = $1
-	`, tournamentID).Scan(&createdBy)
-	if err != nil {
-		return err
-	}
-
-	if reporterID != player1ID && reporterID != player2ID && reporterID != createdBy {
-		return errors.New("no tienes permiso para reportar este match")
+		return ErrMatchAlreadyReported
 	}
 
-	// 3. Actualizar el match
+	// 2. Actualizar el match (los permisos del reportero se comprueban con auth.CanReportMatch).
+	// La condición sobre status evita que dos reportes simultáneos lo completen dos veces.
 	query := `
         UPDATE matches
         SET winner_id = $1, status = 'completed', played_at = NOW()
-        WHERE id = $2
+        WHERE id = $2 AND status = 'pending'
     `
-	_, err = DB.Exec(context.Background(), query, winnerID, matchID)
+	tag, err := DB.Exec(context.Background(), query, winnerID, matchID)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrMatchAlreadyReported
+	}
+
+	// 3. Evaluar logros del ganador (antes de avanzar, con los puntos previos a la final)
+	loserID := 0
+	if winnerID == player1ID {
+		loserID = player2ID
+	} else if winnerID == player2ID {
+		loserID = player1ID
+	}
+	evaluateMatchAchievements(matchID, winnerID, loserID)
 
 	// 4. Avanzar automáticamente al ganador a la siguiente ronda
 	err = AdvanceWinnerToNextRound(matchID, winnerID)
@@ -245,61 +250,27 @@ func AdvanceWinnerToNextRound(matchID, winnerID int) error {
 	}
 
 	if pendingCount == 0 && nextRoundCount == 0 {
-		// Registrar el campeón y marcar como finalizado
-		_, err := DB.Exec(context.Background(), `
-            UPDATE tournaments
-            SET champion_id = $1, is_finished = TRUE
-            WHERE id = $2
-        `, winnerID, tournamentID)
-		if err != nil {
-			return fmt.Errorf("no se pudo registrar al campeón ni finalizar el torneo: %v", err)
-		}
-
-		// Sumar +50 puntos al ganador
-		_, err = DB.Exec(context.Background(), `
-            UPDATE users
-            SET points = points + 50
-            WHERE id = $1
-        `, winnerID)
+		runnerUpID, err := finishTournament(tournamentID, matchID, winnerID)
 		if err != nil {
-			return fmt.Errorf("no se pudo actualizar los puntos del ganador: %v", err)
+			return err
 		}
 
-		// Obtener subcampeón (jugador que perdió la final)
-		var player1ID, player2ID int
-		err = DB.QueryRow(context.Background(), `
-            SELECT COALESCE(player1_id, 0), COALESCE(player2_id, 0)
-            FROM matches
-            WHERE id = $1
-        `, matchID).Scan(&player1ID, &player2ID)
+		// Emitir notificación de torneo finalizado
+		champion, err := GetUserByID(winnerID)
 		if err != nil {
-			return fmt.Errorf("no se pudo obtener los jugadores de la final: %v", err)
-		}
-
-		var runnerUpID int
-		if player1ID != winnerID && player1ID != 0 {
-			runnerUpID = player1ID
-		} else if player2ID != winnerID && player2ID != 0 {
-			runnerUpID = player2ID
+			return fmt.Errorf("no se pudo obtener el campeón: %v", err)
 		}
-
+		var runnerUp *models.User
 		if runnerUpID != 0 {
-			// Sumar +30 puntos al subcampeón
-			_, err = DB.Exec(context.Background(), `
-                UPDATE users
-                SET points = points + 30
-                WHERE id = $1
-            `, runnerUpID)
+			runnerUp, err = GetUserByID(runnerUpID)
 			if err != nil {
-				return fmt.Errorf("no se pudo actualizar los puntos del subcampeón: %v", err)
+				return fmt.Errorf("no se pudo obtener el subcampeón: %v", err)
 			}
 		}
+		realtime.Broadcast(realtime.NewTournamentFinishedEvent(tournamentID, champion, runnerUp))
+		notifyTournamentFinished(tournamentID, champion)
 
-		// Emitir notificación de torneo finalizado
-		realtime.Broadcast(fmt.Sprintf(
-			"EVENT:WINNER|TOURNAMENT:%d|WINNER_ID:%d|MESSAGE:Torneo finalizado",
-			tournamentID, winnerID,
-		))
+		evaluateTournamentAchievements(tournamentID)
 
 		return nil
 	}
@@ -344,12 +315,18 @@ func AdvanceWinnerToNextRound(matchID, winnerID int) error {
 			_, err := DB.Exec(context.Background(), `
                 UPDATE matches SET player1_id = $1 WHERE id = $2
             `, winnerID, matchID)
+			if err == nil {
+				NotifyMatchReady(matchID)
+			}
 			return err
 		}
 		if p2ID == nil {
 			_, err := DB.Exec(context.Background(), `
                 UPDATE matches SET player2_id = $1 WHERE id = $2
             `, winnerID, matchID)
+			if err == nil {
+				NotifyMatchReady(matchID)
+			}
 			return err
 		}
 	}
@@ -363,6 +340,75 @@ func AdvanceWinnerToNextRound(matchID, winnerID int) error {
 	return err
 }
 
+// finishTournament registra al campeón, marca el torneo como finalizado, suma los
+// puntos de la final y guarda la clasificación en una sola transacción. Devuelve el
+// subcampeón (0 si no lo hay).
+func finishTournament(tournamentID, finalMatchID, winnerID int) (int, error) {
+	ctx := context.Background()
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Registrar el campeón y marcar como finalizado
+	_, err = tx.Exec(ctx, `
+        UPDATE tournaments
+        SET champion_id = $1, is_finished = TRUE
+        WHERE id = $2
+    `, winnerID, tournamentID)
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo registrar al campeón ni finalizar el torneo: %v", err)
+	}
+
+	// Sumar +50 puntos al ganador
+	_, err = tx.Exec(ctx, `
+        UPDATE users
+        SET points = points + $2
+        WHERE id = $1
+    `, winnerID, ChampionPoints)
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo actualizar los puntos del ganador: %v", err)
+	}
+
+	// Obtener subcampeón (jugador que perdió la final)
+	var player1ID, player2ID int
+	err = tx.QueryRow(ctx, `
+        SELECT COALESCE(player1_id, 0), COALESCE(player2_id, 0)
+        FROM matches
+        WHERE id = $1
+    `, finalMatchID).Scan(&player1ID, &player2ID)
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo obtener los jugadores de la final: %v", err)
+	}
+
+	var runnerUpID int
+	if player1ID != winnerID && player1ID != 0 {
+		runnerUpID = player1ID
+	} else if player2ID != winnerID && player2ID != 0 {
+		runnerUpID = player2ID
+	}
+
+	if runnerUpID != 0 {
+		// Sumar +30 puntos al subcampeón
+		_, err = tx.Exec(ctx, `
+            UPDATE users
+            SET points = points + $2
+            WHERE id = $1
+        `, runnerUpID, RunnerUpPoints)
+		if err != nil {
+			return 0, fmt.Errorf("no se pudo actualizar los puntos del subcampeón: %v", err)
+		}
+	}
+
+	// Guardar la clasificación final del torneo
+	if err := computeTournamentPlacements(ctx, tx, tournamentID); err != nil {
+		return 0, fmt.Errorf("no se pudo calcular la clasificación final: %v", err)
+	}
+
+	return runnerUpID, tx.Commit(ctx)
+}
+
 func UploadMatchScreenshot(c *gin.Context) {
 	// Obtener el ID del match
 	matchIDStr := c.Param("id")
@@ -416,4 +462,152 @@ func UploadMatchScreenshot(c *gin.Context) {
 		"message":        "Screenshot uploaded successfully",
 		"screenshot_url": relativePath,
 	})
-}
\ No newline at end of file
+}
+const matchDetailQuery = `
+    SELECT
+        m.id, m.tournament_id, m.round, m.status, m.played_at, m.screenshot_url,
+        m.player1_id, m.player2_id, m.winner_id,
+        u1.username, u1.avatar_url,
+        u2.username, u2.avatar_url,
+        uw.username, uw.avatar_url
+    FROM matches m
+    LEFT JOIN users u1 ON m.player1_id = u1.id
+    LEFT JOIN users u2 ON m.player2_id = u2.id
+    LEFT JOIN users uw ON m.winner_id = uw.id
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMatchDetail(row rowScanner) (*models.Match, error) {
+	var m models.Match
+	var p1Name, p1Avatar, p2Name, p2Avatar, wName, wAvatar *string
+
+	err := row.Scan(
+		&m.ID, &m.TournamentID, &m.Round, &m.Status, &m.PlayedAt, &m.ScreenshotURL,
+		&m.Player1ID, &m.Player2ID, &m.WinnerID,
+		&p1Name, &p1Avatar,
+		&p2Name, &p2Avatar,
+		&wName, &wAvatar,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Player1 = matchUser(m.Player1ID, p1Name, p1Avatar)
+	m.Player2 = matchUser(m.Player2ID, p2Name, p2Avatar)
+	m.Winner = matchUser(m.WinnerID, wName, wAvatar)
+	return &m, nil
+}
+
+func matchUser(id *int, username, avatarURL *string) *models.User {
+	if id == nil {
+		return nil
+	}
+	u := &models.User{ID: *id}
+	if username != nil {
+		u.Username = *username
+	}
+	if avatarURL != nil {
+		u.AvatarURL = *avatarURL
+	}
+	return u
+}
+
+// GetMatchByID devuelve un match con los datos básicos de sus jugadores y del ganador
+func GetMatchByID(matchID int) (*models.Match, error) {
+	row := DB.QueryRow(context.Background(), matchDetailQuery+` WHERE m.id = $1`, matchID)
+	return scanMatchDetail(row)
+}
+
+// GetMatchDetailsByTournamentID devuelve los matches de un torneo con sus jugadores
+func GetMatchDetailsByTournamentID(tournamentID int) ([]models.Match, error) {
+	rows, err := DB.Query(context.Background(), matchDetailQuery+`
+        WHERE m.tournament_id = $1
+        ORDER BY m.round, m.id`, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []models.Match{}
+	for rows.Next() {
+		m, err := scanMatchDetail(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, *m)
+	}
+
+	return matches, rows.Err()
+}
+
+// NotifyMatchReady avisa en privado a los dos jugadores cuando su match pendiente ya tiene rival
+func NotifyMatchReady(matchID int) {
+	m, err := GetMatchByID(matchID)
+	if err != nil {
+		log.Printf("Error obteniendo el match %d para notificar: %v", matchID, err)
+		return
+	}
+
+	if m.Status != "pending" || m.Player1ID == nil || m.Player2ID == nil {
+		return
+	}
+
+	event := realtime.NewMatchReadyEvent(*m)
+	realtime.SendToUser(*m.Player1ID, event)
+	realtime.SendToUser(*m.Player2ID, event)
+
+	name := tournamentName(m.TournamentID)
+	for _, pair := range [][2]*models.User{{m.Player1, m.Player2}, {m.Player2, m.Player1}} {
+		Notify(models.Notification{
+			UserID:       pair[0].ID,
+			Type:         models.NotificationMatchReady,
+			Title:        "Tu match está listo",
+			Body:         fmt.Sprintf("Ronda %d de %s contra %s", m.Round, name, pair[1].Username),
+			TournamentID: &m.TournamentID,
+			MatchID:      &m.ID,
+		})
+	}
+}
+
+// GetNextMatchForPlayer devuelve el primer match pendiente del jugador en el torneo
+// (en cualquier torneo si tournamentID es 0), o nil si no le queda ninguno
+func GetNextMatchForPlayer(tournamentID, userID int) (*models.Match, error) {
+	row := DB.QueryRow(context.Background(), matchDetailQuery+`
+        WHERE ($1 = 0 OR m.tournament_id = $1) AND m.status = 'pending'
+          AND (m.player1_id = $2 OR m.player2_id = $2)
+        ORDER BY m.round, m.id
+        LIMIT 1`, tournamentID, userID)
+
+	m, err := scanMatchDetail(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return m, err
+}
+
+// PublishMatchResult emite el resultado recién reportado: evento público, aviso privado
+// al rival de quien reportó y notificaciones. Lo usan todos los puntos de entrada que
+// reportan resultados (API REST y comandos de Discord).
+func PublishMatchResult(matchID, reporterID int) (*models.Match, error) {
+	match, err := GetMatchByID(matchID)
+	if err != nil {
+		return nil, err
+	}
+
+	realtime.Broadcast(realtime.NewMatchResultEvent(*match))
+
+	// Avisar en privado al rival de quien ha reportado
+	if match.Player1ID != nil && match.Player2ID != nil {
+		if *match.Player1ID == reporterID {
+			realtime.SendToUser(*match.Player2ID, realtime.NewOpponentReportedEvent(*match, reporterID))
+		} else if *match.Player2ID == reporterID {
+			realtime.SendToUser(*match.Player1ID, realtime.NewOpponentReportedEvent(*match, reporterID))
+		}
+	}
+
+	NotifyMatchReported(*match, reporterID)
+	return match, nil
+}
@@ -0,0 +1,347 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"torneos/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrBannedFromTournament = errors.New("tienes una sanción que te impide participar en este torneo")
+	ErrReportPending        = errors.New("ya tienes un reporte pendiente sobre este usuario")
+	ErrReportNotPending     = errors.New("reporte no encontrado o ya revisado")
+	ErrBanTournamentMissing = errors.New("torneo no encontrado")
+)
+
+// Condición de sanción vigente sobre la tabla bans con alias b
+const activeBan = `b.revoked_at IS NULL AND (b.expires_at IS NULL OR b.expires_at > NOW())`
+
+// IsUserBanned indica si el usuario tiene una sanción global vigente o, con
+// tournamentID distinto de 0, una sanción en ese torneo
+func IsUserBanned(userID, tournamentID int) (bool, error) {
+	var banned bool
+	err := DB.QueryRow(context.Background(), `
+        SELECT EXISTS (
+            SELECT 1 FROM bans b
+            WHERE b.user_id = $1 AND (b.tournament_id IS NULL OR b.tournament_id = $2)
+              AND `+activeBan+`
+        )
+    `, userID, tournamentID).Scan(&banned)
+	return banned, err
+}
+
+// CreateBan registra la sanción en el historial de moderación. Con reportID el reporte
+// queda resuelto con esta sanción. ttl 0 = sin caducidad. Una sanción global cierra
+// además todas las sesiones del usuario.
+func CreateBan(b *models.Ban, ttl time.Duration, reportID *int) error {
+	var expires *string
+	if ttl > 0 {
+		s := ttl.String()
+		expires = &s
+	}
+
+	ctx := context.Background()
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+        INSERT INTO bans (user_id, tournament_id, reason, expires_at, created_by_user_id)
+        VALUES ($1, $2, $3, NOW() + $4::interval, $5)
+        RETURNING id, expires_at, created_at
+    `, b.UserID, b.TournamentID, b.Reason, expires, b.CreatedByUserID).Scan(&b.ID, &b.ExpiresAt, &b.CreatedAt)
+	if isForeignKeyViolation(err, "bans_tournament_id_fkey") {
+		return ErrBanTournamentMissing
+	}
+	if err != nil {
+		return err
+	}
+	b.Active = true
+
+	if reportID != nil {
+		tag, err := tx.Exec(ctx, `
+            UPDATE user_reports
+            SET status = 'actioned', reviewed_by_user_id = $2, reviewed_at = NOW(), resolution = $3
+            WHERE id = $1 AND status = 'pending' AND reported_user_id = $4
+        `, *reportID, b.CreatedByUserID, b.Reason, b.UserID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrReportNotPending
+		}
+	}
+
+	details := b.Reason
+	if b.ExpiresAt != nil {
+		details += " (hasta " + b.ExpiresAt.Format(time.RFC3339) + ")"
+	}
+	if err := logModerationAction(ctx, tx, models.ModerationAction{
+		ActorUserID:  b.CreatedByUserID,
+		Action:       models.ModerationBan,
+		TargetUserID: b.UserID,
+		TournamentID: b.TournamentID,
+		BanID:        &b.ID,
+		ReportID:     reportID,
+		Details:      details,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if b.TournamentID == nil {
+		return RevokeUserSessions(b.UserID)
+	}
+	return nil
+}
+
+// RevokeBan levanta una sanción vigente. Con tournamentID solo se aceptan sanciones de
+// ese torneo (los organizadores no pueden levantar sanciones globales).
+func RevokeBan(banID int, tournamentID *int, actorID int) error {
+	ctx := context.Background()
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID int
+	var banTournamentID *int
+	err = tx.QueryRow(ctx, `
+        UPDATE bans b SET revoked_at = NOW(), revoked_by_user_id = $3
+        WHERE b.id = $1 AND ($2::int IS NULL OR b.tournament_id = $2) AND `+activeBan+`
+        RETURNING b.user_id, b.tournament_id
+    `, banID, tournamentID, actorID).Scan(&userID, &banTournamentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("sanción no encontrada o ya no está vigente")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := logModerationAction(ctx, tx, models.ModerationAction{
+		ActorUserID:  &actorID,
+		Action:       models.ModerationUnban,
+		TargetUserID: userID,
+		TournamentID: banTournamentID,
+		BanID:        &banID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// BanFilter agrupa los filtros y la paginación de la lista de sanciones
+type BanFilter struct {
+	UserID       int
+	TournamentID int
+	// Solo sanciones globales
+	SiteOnly   bool
+	ActiveOnly bool
+	Limit      int
+	Offset     int
+}
+
+func GetBans(f BanFilter) ([]models.Ban, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.UserID != 0 {
+		add("b.user_id = $%d", f.UserID)
+	}
+	if f.TournamentID != 0 {
+		add("b.tournament_id = $%d", f.TournamentID)
+	}
+	if f.SiteOnly {
+		conditions = append(conditions, "b.tournament_id IS NULL")
+	}
+	if f.ActiveOnly {
+		conditions = append(conditions, activeBan)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := DB.Query(context.Background(), `
+        SELECT b.id, b.user_id, u.username, b.tournament_id, b.reason, b.expires_at,
+               b.created_by_user_id, b.created_at, b.revoked_at, b.revoked_by_user_id,
+               (`+activeBan+`)
+        FROM bans b
+        JOIN users u ON u.id = b.user_id
+        `+where+`
+        ORDER BY b.created_at DESC, b.id DESC
+        LIMIT $`+fmt.Sprint(len(args)-1)+` OFFSET $`+fmt.Sprint(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []models.Ban{}
+	for rows.Next() {
+		var b models.Ban
+		err := rows.Scan(&b.ID, &b.UserID, &b.Username, &b.TournamentID, &b.Reason, &b.ExpiresAt,
+			&b.CreatedByUserID, &b.CreatedAt, &b.RevokedAt, &b.RevokedByUserID, &b.Active)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+
+	return bans, rows.Err()
+}
+
+func CreateUserReport(r *models.UserReport) error {
+	err := DB.QueryRow(context.Background(), `
+        INSERT INTO user_reports (reporter_id, reported_user_id, tournament_id, match_id, reason, details)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+        RETURNING id, status, created_at
+    `, r.ReporterID, r.ReportedUserID, r.TournamentID, r.MatchID, r.Reason, r.Details).
+		Scan(&r.ID, &r.Status, &r.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrReportPending
+	}
+	return err
+}
+
+// GetUserReports devuelve la cola de reportes con el estado indicado ("" = todos),
+// los más antiguos primero, y el total
+func GetUserReports(status string, limit, offset int) ([]models.UserReport, int, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT r.id, r.reporter_id, COALESCE(ru.username, ''), r.reported_user_id, u.username,
+               r.tournament_id, r.match_id, r.reason, COALESCE(r.details, ''), r.status,
+               r.reviewed_by_user_id, r.reviewed_at, COALESCE(r.resolution, ''), r.created_at,
+               COUNT(*) OVER ()
+        FROM user_reports r
+        JOIN users u ON u.id = r.reported_user_id
+        LEFT JOIN users ru ON ru.id = r.reporter_id
+        WHERE $1 = '' OR r.status = $1
+        ORDER BY r.created_at, r.id
+        LIMIT $2 OFFSET $3
+    `, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reports := []models.UserReport{}
+	total := 0
+	for rows.Next() {
+		var r models.UserReport
+		err := rows.Scan(&r.ID, &r.ReporterID, &r.ReporterUsername, &r.ReportedUserID, &r.ReportedUsername,
+			&r.TournamentID, &r.MatchID, &r.Reason, &r.Details, &r.Status,
+			&r.ReviewedByUserID, &r.ReviewedAt, &r.Resolution, &r.CreatedAt, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		reports = append(reports, r)
+	}
+
+	return reports, total, rows.Err()
+}
+
+// GetPendingReportTarget devuelve el usuario reportado y el torneo de un reporte pendiente
+func GetPendingReportTarget(reportID int) (int, *int, error) {
+	var userID int
+	var tournamentID *int
+	err := DB.QueryRow(context.Background(), `
+        SELECT reported_user_id, tournament_id FROM user_reports WHERE id = $1 AND status = 'pending'
+    `, reportID).Scan(&userID, &tournamentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, ErrReportNotPending
+	}
+	return userID, tournamentID, err
+}
+
+// DismissReport cierra un reporte pendiente sin sanción
+func DismissReport(reportID, actorID int, resolution string) error {
+	ctx := context.Background()
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID int
+	var tournamentID *int
+	err = tx.QueryRow(ctx, `
+        UPDATE user_reports
+        SET status = 'dismissed', reviewed_by_user_id = $2, reviewed_at = NOW(), resolution = NULLIF($3, '')
+        WHERE id = $1 AND status = 'pending'
+        RETURNING reported_user_id, tournament_id
+    `, reportID, actorID, resolution).Scan(&userID, &tournamentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrReportNotPending
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := logModerationAction(ctx, tx, models.ModerationAction{
+		ActorUserID:  &actorID,
+		Action:       models.ModerationReportDismiss,
+		TargetUserID: userID,
+		TournamentID: tournamentID,
+		ReportID:     &reportID,
+		Details:      resolution,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func logModerationAction(ctx context.Context, tx pgx.Tx, a models.ModerationAction) error {
+	_, err := tx.Exec(ctx, `
+        INSERT INTO moderation_actions (actor_user_id, action, target_user_id, tournament_id, ban_id, report_id, details)
+        VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+    `, a.ActorUserID, a.Action, a.TargetUserID, a.TournamentID, a.BanID, a.ReportID, a.Details)
+	return err
+}
+
+// GetModerationActions devuelve el historial de moderación, de un usuario si
+// targetUserID no es 0, los más recientes primero
+func GetModerationActions(targetUserID, limit, offset int) ([]models.ModerationAction, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT id, actor_user_id, action, target_user_id, tournament_id, ban_id, report_id,
+               COALESCE(details, ''), created_at
+        FROM moderation_actions
+        WHERE $1 = 0 OR target_user_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2 OFFSET $3
+    `, targetUserID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []models.ModerationAction{}
+	for rows.Next() {
+		var a models.ModerationAction
+		err := rows.Scan(&a.ID, &a.ActorUserID, &a.Action, &a.TargetUserID, &a.TournamentID, &a.BanID,
+			&a.ReportID, &a.Details, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"torneos/models"
+	"torneos/realtime"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Notify guarda la notificación (salvo que el usuario haya desactivado ese tipo) y la
+// envía por su socket. Los errores se registran pero no interrumpen el flujo que notifica.
+func Notify(n models.Notification) {
+	err := DB.QueryRow(context.Background(), `
+        INSERT INTO notifications (user_id, type, title, body, tournament_id, match_id)
+        SELECT $1, $2, $3, $4, $5, $6
+        WHERE NOT EXISTS (
+            SELECT 1 FROM notification_preferences
+            WHERE user_id = $1 AND type = $2 AND NOT enabled
+        )
+        RETURNING id, created_at
+    `, n.UserID, n.Type, n.Title, n.Body, n.TournamentID, n.MatchID).Scan(&n.ID, &n.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error guardando la notificación %s para el usuario %d: %v", n.Type, n.UserID, err)
+		return
+	}
+
+	realtime.SendToUser(n.UserID, realtime.NewNotificationEvent(n))
+}
+
+// GetNotifications devuelve una página de notificaciones del usuario (las más recientes
+// primero), el total y cuántas quedan sin leer
+func GetNotifications(userID, limit, offset int, unreadOnly bool) ([]models.Notification, int, int, error) {
+	var total, unread int
+	err := DB.QueryRow(context.Background(), `
+        SELECT
+            COUNT(*) FILTER (WHERE NOT $2 OR read_at IS NULL),
+            COUNT(*) FILTER (WHERE read_at IS NULL)
+        FROM notifications
+        WHERE user_id = $1
+    `, userID, unreadOnly).Scan(&total, &unread)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	rows, err := DB.Query(context.Background(), `
+        SELECT id, user_id, type, title, body, tournament_id, match_id, read_at, created_at
+        FROM notifications
+        WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $3 OFFSET $4
+    `, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Title, &n.Body,
+			&n.TournamentID, &n.MatchID, &n.ReadAt, &n.CreatedAt)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, total, unread, rows.Err()
+}
+
+func MarkNotificationRead(userID, notificationID int) error {
+	tag, err := DB.Exec(context.Background(), `
+        UPDATE notifications
+        SET read_at = COALESCE(read_at, NOW())
+        WHERE id = $1 AND user_id = $2
+    `, notificationID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("notificación no encontrada")
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marca como leídas todas las notificaciones pendientes y
+// devuelve cuántas se han marcado
+func MarkAllNotificationsRead(userID int) (int64, error) {
+	tag, err := DB.Exec(context.Background(), `
+        UPDATE notifications SET read_at = NOW()
+        WHERE user_id = $1 AND read_at IS NULL
+    `, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetNotificationPreferences devuelve todos los tipos de notificación; los que el
+// usuario no ha configurado están activados
+func GetNotificationPreferences(userID int) ([]models.NotificationPreference, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT type, enabled FROM notification_preferences WHERE user_id = $1
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	configured := map[string]bool{}
+	for rows.Next() {
+		var t string
+		var enabled bool
+		if err := rows.Scan(&t, &enabled); err != nil {
+			return nil, err
+		}
+		configured[t] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	prefs := []models.NotificationPreference{}
+	for _, t := range models.NotificationTypes {
+		enabled, ok := configured[t]
+		prefs = append(prefs, models.NotificationPreference{Type: t, Enabled: !ok || enabled})
+	}
+	return prefs, nil
+}
+
+func SetNotificationPreferences(userID int, prefs []models.NotificationPreference) error {
+	tx, err := DB.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	for _, p := range prefs {
+		_, err := tx.Exec(context.Background(), `
+            INSERT INTO notification_preferences (user_id, type, enabled)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled
+        `, userID, p.Type, p.Enabled)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(context.Background())
+}
+
+func tournamentName(tournamentID int) string {
+	var name string
+	if err := DB.QueryRow(context.Background(), `SELECT name FROM tournaments WHERE id = $1`, tournamentID).Scan(&name); err != nil {
+		return fmt.Sprintf("torneo #%d", tournamentID)
+	}
+	return name
+}
+
+// NotifyBracketGenerated avisa a todos los participantes de que el torneo ha empezado
+func NotifyBracketGenerated(tournamentID int) {
+	participants, err := GetParticipantsByTournamentID(tournamentID)
+	if err != nil {
+		log.Printf("Error obteniendo participantes del torneo %d para notificar: %v", tournamentID, err)
+		return
+	}
+
+	name := tournamentName(tournamentID)
+	for _, u := range participants {
+		Notify(models.Notification{
+			UserID:       u.ID,
+			Type:         models.NotificationBracketGenerated,
+			Title:        "Bracket generado",
+			Body:         fmt.Sprintf("El bracket de %s ya está disponible", name),
+			TournamentID: &tournamentID,
+		})
+	}
+}
+
+// NotifyMatchReported avisa a los jugadores del match que no han reportado el resultado.
+// Si lo reportó su rival la notificación es opponent_reported, para que pueda revisarlo.
+func NotifyMatchReported(m models.Match, reporterID int) {
+	winner := "desconocido"
+	if m.Winner != nil {
+		winner = m.Winner.Username
+	}
+
+	for _, playerID := range []*int{m.Player1ID, m.Player2ID} {
+		if playerID == nil || *playerID == reporterID {
+			continue
+		}
+
+		n := models.Notification{
+			UserID:       *playerID,
+			Type:         models.NotificationMatchResult,
+			Title:        "Resultado registrado",
+			Body:         fmt.Sprintf("Ronda %d de %s: ganador %s", m.Round, tournamentName(m.TournamentID), winner),
+			TournamentID: &m.TournamentID,
+			MatchID:      &m.ID,
+		}
+		if m.Player1ID != nil && m.Player2ID != nil && (*m.Player1ID == reporterID || *m.Player2ID == reporterID) {
+			n.Type = models.NotificationOpponentReported
+			n.Title = "Tu rival ha reportado el resultado"
+		}
+		Notify(n)
+	}
+}
+
+// notifyTournamentFinished avisa a todos los participantes del campeón del torneo
+func notifyTournamentFinished(tournamentID int, champion *models.User) {
+	participants, err := GetParticipantsByTournamentID(tournamentID)
+	if err != nil {
+		log.Printf("Error obteniendo participantes del torneo %d para notificar: %v", tournamentID, err)
+		return
+	}
+
+	name := tournamentName(tournamentID)
+	for _, u := range participants {
+		body := fmt.Sprintf("%s ha terminado. Campeón: %s", name, champion.Username)
+		if u.ID == champion.ID {
+			body = fmt.Sprintf("¡Has ganado %s!", name)
+		}
+
+		Notify(models.Notification{
+			UserID:       u.ID,
+			Type:         models.NotificationTournamentFinished,
+			Title:        "Torneo finalizado",
+			Body:         body,
+			TournamentID: &tournamentID,
+		})
+	}
+}
@@ -4,11 +4,20 @@ import (
 	"context"
 	"errors"
 	"torneos/models"
+	"torneos/realtime"
 
-	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func JoinTournament(userID int, tournamentID int) (*models.Participant, error) {
+	banned, err := IsUserBanned(userID, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, ErrBannedFromTournament
+	}
+
 	query := `
         INSERT INTO participants (user_id, tournament_id)
         VALUES ($1, $2)
@@ -16,7 +25,7 @@ func JoinTournament(userID int, tournamentID int) (*models.Participant, error) {
     `
 
 	var p models.Participant
-	err := DB.QueryRow(context.Background(), query, userID, tournamentID).
+	err = DB.QueryRow(context.Background(), query, userID, tournamentID).
 		Scan(&p.ID, &p.JoinedAt)
 
 	if err != nil {
@@ -29,19 +38,67 @@ func JoinTournament(userID int, tournamentID int) (*models.Participant, error) {
 
 	p.UserID = userID
 	p.TournamentID = tournamentID
+
+	if user, err := GetUserByID(userID); err == nil {
+		realtime.Broadcast(realtime.NewParticipantJoinedEvent(tournamentID, user))
+	}
+
 	return &p, nil
 }
 
+// CheckInParticipant confirma la asistencia de un participante antes de que empiece
+// el torneo. Repetir el check-in no cambia la hora original.
+func CheckInParticipant(userID, tournamentID int) error {
+	banned, err := IsUserBanned(userID, tournamentID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return ErrBannedFromTournament
+	}
+
+	started, err := HasBracket(tournamentID)
+	if err != nil {
+		return err
+	}
+	if started {
+		return errors.New("el check-in se cierra al generar el bracket")
+	}
+
+	tag, err := DB.Exec(context.Background(), `
+        UPDATE participants
+        SET checked_in_at = COALESCE(checked_in_at, NOW())
+        WHERE user_id = $1 AND tournament_id = $2
+    `, userID, tournamentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("no estás inscrito en este torneo")
+	}
+	return nil
+}
+
 func isUniqueViolation(err error) bool {
-	if pgErr, ok := err.(*pgconn.PgError); ok {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
 		return pgErr.Code == "23505"
 	}
 	return false
 }
 
+// isForeignKeyViolation indica si err es la violación de la clave ajena constraint
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23503" && pgErr.ConstraintName == constraint
+	}
+	return false
+}
+
 func GetParticipantsByTournamentID(tournamentID int) ([]models.User, error) {
 	query := `
-        SELECT u.id, u.username, u.email, u.oauth_provider, u.oauth_id, u.avatar_url, u.created_at
+        SELECT u.id, u.username, COALESCE(u.avatar_url, ''), u.created_at
         FROM participants p
         JOIN users u ON u.id = p.user_id
         WHERE p.tournament_id = $1;
@@ -56,7 +113,7 @@ func GetParticipantsByTournamentID(tournamentID int) ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.OAuthProvider, &u.OAuthID, &u.AvatarURL, &u.CreatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.AvatarURL, &u.CreatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"torneos/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ComputeTournamentPlacements calcula y guarda la clasificación final de un torneo.
+// Los jugadores se ordenan por la última ronda que alcanzaron (el campeón por delante
+// del finalista) y los que caen en la misma ronda comparten puesto: 1º, 2º, 3º-4º, 5º-8º...
+// Los inscritos que no llegaron a jugar ningún match quedan con round_reached = 0.
+func ComputeTournamentPlacements(tournamentID int) error {
+	ctx := context.Background()
+
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := computeTournamentPlacements(ctx, tx, tournamentID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// computeTournamentPlacements calcula la clasificación dentro de la transacción tx
+func computeTournamentPlacements(ctx context.Context, tx pgx.Tx, tournamentID int) error {
+	_, err := tx.Exec(ctx, `DELETE FROM placements WHERE tournament_id = $1`, tournamentID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+        WITH reached AS (
+            SELECT p.user_id,
+                COALESCE(MAX(m.round), 0) AS round_reached,
+                (t.champion_id IS NOT NULL AND t.champion_id = p.user_id) AS is_champion
+            FROM participants p
+            JOIN tournaments t ON t.id = p.tournament_id
+            LEFT JOIN matches m ON m.tournament_id = p.tournament_id
+                AND (m.player1_id = p.user_id OR m.player2_id = p.user_id)
+            WHERE p.tournament_id = $1
+            GROUP BY p.user_id, t.champion_id
+        ),
+        ranked AS (
+            SELECT user_id, round_reached,
+                RANK() OVER (ORDER BY round_reached DESC, is_champion DESC) AS placement,
+                COUNT(*) OVER (PARTITION BY round_reached, is_champion) AS tied
+            FROM reached
+        )
+        INSERT INTO placements (tournament_id, user_id, placement, placement_max, round_reached)
+        SELECT $1, user_id, placement, placement + tied - 1, round_reached
+        FROM ranked
+    `, tournamentID)
+	return err
+}
+
+// BackfillPlacements calcula la clasificación de los torneos finalizados que aún no la tienen
+func BackfillPlacements() error {
+	rows, err := DB.Query(context.Background(), `
+        SELECT t.id
+        FROM tournaments t
+        WHERE t.is_finished
+          AND NOT EXISTS (SELECT 1 FROM placements pl WHERE pl.tournament_id = t.id)
+    `)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := ComputeTournamentPlacements(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetTournamentPlacements(tournamentID int) ([]models.Placement, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT pl.user_id, u.username, COALESCE(u.avatar_url, ''), pl.placement, pl.placement_max, pl.round_reached
+        FROM placements pl
+        JOIN users u ON u.id = pl.user_id
+        WHERE pl.tournament_id = $1
+        ORDER BY pl.placement, u.username
+    `, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	placements := []models.Placement{}
+	for rows.Next() {
+		var p models.Placement
+		if err := rows.Scan(&p.UserID, &p.Username, &p.AvatarURL, &p.Placement, &p.PlacementMax, &p.RoundReached); err != nil {
+			return nil, err
+		}
+		p.Label = placementLabel(p.Placement, p.PlacementMax)
+		placements = append(placements, p)
+	}
+
+	return placements, rows.Err()
+}
+
+// placementLabel formatea un puesto como "1", "2", "3-4", "5-8"...
+func placementLabel(placement, placementMax int) string {
+	if placementMax <= placement {
+		return strconv.Itoa(placement)
+	}
+	return strconv.Itoa(placement) + "-" + strconv.Itoa(placementMax)
+}
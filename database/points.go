@@ -0,0 +1,40 @@
+package database
+
+import "fmt"
+
+// Puntos del ranking. Se suman a users.points al generar el bracket (participación) y
+// al terminar el torneo (campeón y finalista).
+const (
+	ParticipationPoints = 5
+	ChampionPoints      = 50
+	RunnerUpPoints      = 30
+)
+
+// pointAwardsCTE es la CTE "point_awards" (tournament_id, user_id, points, awarded_at)
+// con los puntos que reparte cada torneo, según las mismas reglas con las que se suman
+// a users.points: la participación en cada torneo con bracket, con la fecha de inicio
+// del torneo, y el campeón y el finalista de cada torneo finalizado, con la fecha en
+// que se jugó la final. Es la única definición de los puntos por torneo; el ranking por
+// juego, el historial de puntos y el backfill de logros la comparten.
+var pointAwardsCTE = fmt.Sprintf(`
+    point_finals AS (
+        SELECT DISTINCT ON (m.tournament_id)
+            m.tournament_id, t.champion_id, m.player1_id, m.player2_id, m.played_at
+        FROM matches m
+        JOIN tournaments t ON t.id = m.tournament_id AND t.is_finished AND t.champion_id IS NOT NULL
+        ORDER BY m.tournament_id, m.round DESC, m.id DESC
+    ),
+    point_awards AS (
+        SELECT p.tournament_id, p.user_id, %d AS points, t.start_time AS awarded_at
+        FROM participants p
+        JOIN tournaments t ON t.id = p.tournament_id
+        WHERE EXISTS (SELECT 1 FROM matches m WHERE m.tournament_id = p.tournament_id)
+        UNION ALL
+        SELECT tournament_id, champion_id, %d, played_at FROM point_finals
+        UNION ALL
+        SELECT tournament_id,
+               CASE WHEN player1_id = champion_id THEN player2_id ELSE player1_id END,
+               %d, played_at
+        FROM point_finals
+        WHERE player1_id IS NOT NULL AND player2_id IS NOT NULL
+    )`, ParticipationPoints, ChampionPoints, RunnerUpPoints)
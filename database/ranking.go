@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotRanked indica que el usuario no tiene puntos en el ranking pedido
+var ErrNotRanked = errors.New("el usuario no aparece en el ranking")
+
+// RankingFilter agrupa los filtros y la paginación del ranking. ViewerID (0 = sin
+// sesión) y ViewerIsAdmin deciden qué usuarios aparecen según stats_visibility.
+type RankingFilter struct {
+	Game          string
+	Platform      string
+	Limit         int
+	Offset        int
+	ViewerID      int
+	ViewerIsAdmin bool
+}
+
+func (f RankingFilter) hasFilters() bool {
+	return f.Game != "" || f.Platform != ""
+}
+
+// rankingQuery construye la CTE "ranking" (id, username, avatar_url, points, rank, position).
+// Sin filtros se usan los puntos acumulados en users.points; con filtros de juego o
+// plataforma los puntos se suman a partir de point_awards de los torneos filtrados.
+// Solo aparecen los usuarios que han jugado algún torneo. Las cuentas borradas no
+// aparecen en el ranking, y tampoco los usuarios cuyas estadísticas no puede ver quien
+// consulta (ver statsVisible).
+func rankingQuery(f RankingFilter) (string, []interface{}) {
+	if !f.hasFilters() {
+		return `
+        WITH ` + pointAwardsCTE + `,
+        ranking AS (
+            SELECT
+                u.id, u.username, u.avatar_url, COALESCE(u.points, 0) AS points,
+                DENSE_RANK() OVER (ORDER BY COALESCE(u.points, 0) DESC) AS rank,
+                ROW_NUMBER() OVER (ORDER BY COALESCE(u.points, 0) DESC, u.id) AS position
+            FROM users u
+            WHERE u.deleted_at IS NULL AND ` + statsVisible(1, 2) + `
+              AND EXISTS (SELECT 1 FROM point_awards a WHERE a.user_id = u.id)
+        )`, []interface{}{f.ViewerID, f.ViewerIsAdmin}
+	}
+
+	return `
+        WITH ` + pointAwardsCTE + `,
+        scores AS (
+            SELECT a.user_id, SUM(a.points) AS points
+            FROM point_awards a
+            JOIN tournaments t ON t.id = a.tournament_id
+            WHERE ($1 = '' OR LOWER(t.game) = LOWER($1))
+              AND ($2 = '' OR LOWER(t.platform) = LOWER($2))
+            GROUP BY a.user_id
+        ),
+        ranking AS (
+            SELECT
+                u.id, u.username, u.avatar_url, s.points::int AS points,
+                DENSE_RANK() OVER (ORDER BY s.points DESC) AS rank,
+                ROW_NUMBER() OVER (ORDER BY s.points DESC, u.id) AS position
+            FROM scores s
+            JOIN users u ON u.id = s.user_id
+            WHERE u.deleted_at IS NULL AND ` + statsVisible(3, 4) + `
+        )`, []interface{}{f.Game, f.Platform, f.ViewerID, f.ViewerIsAdmin}
+}
+
+// statsVisible es la condición SQL equivalente a models.Allows sobre stats_visibility
+// de u, con el ID de quien consulta y si es administrador en los parámetros indicados
+func statsVisible(viewerParam, adminParam int) string {
+	return fmt.Sprintf(`(u.stats_visibility = 'public'
+                   OR (u.stats_visibility = 'registered' AND $%[1]d::int <> 0)
+                   OR u.id = $%[1]d::int OR $%[2]d::boolean)`, viewerParam, adminParam)
+}
+
+func scanRankingRows(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := DB.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranking := []map[string]interface{}{}
+	for rows.Next() {
+		var id, points int
+		var rank, position int64
+		var username string
+		var avatarURL *string
+
+		if err := rows.Scan(&id, &username, &avatarURL, &points, &rank, &position); err != nil {
+			return nil, err
+		}
+
+		ranking = append(ranking, map[string]interface{}{
+			"id":         id,
+			"username":   username,
+			"avatar_url": nullString(avatarURL),
+			"points":     points,
+			"rank":       rank,
+			"position":   position,
+		})
+	}
+
+	return ranking, rows.Err()
+}
+
+// GetRanking devuelve una página del ranking y el total de jugadores clasificados
+func GetRanking(f RankingFilter) ([]map[string]interface{}, int, error) {
+	cte, args := rankingQuery(f)
+
+	var total int
+	err := DB.QueryRow(context.Background(), cte+` SELECT COUNT(*) FROM ranking`, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n := len(args)
+	query := cte + fmt.Sprintf(`
+        SELECT id, username, avatar_url, points, rank, position
+        FROM ranking
+        ORDER BY position
+        LIMIT $%d OFFSET $%d`, n+1, n+2)
+
+	ranking, err := scanRankingRows(query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ranking, total, nil
+}
+
+// GetUserRank devuelve la posición del usuario en el ranking junto con los jugadores
+// inmediatamente por encima y por debajo de él entre los que puede ver quien consulta
+func GetUserRank(userID int, f RankingFilter) (map[string]interface{}, error) {
+	cte, args := rankingQuery(f)
+
+	n := len(args)
+	query := cte + fmt.Sprintf(`
+        SELECT r.id, r.username, r.avatar_url, r.points, r.rank, r.position
+        FROM ranking r
+        JOIN ranking me ON me.id = $%d
+        WHERE r.position BETWEEN me.position - 1 AND me.position + 1
+        ORDER BY r.position`, n+1)
+
+	rows, err := scanRankingRows(query, append(args, userID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"user":  nil,
+		"above": nil,
+		"below": nil,
+	}
+
+	var me map[string]interface{}
+	for _, r := range rows {
+		if r["id"] == userID {
+			me = r
+		}
+	}
+	if me == nil {
+		return nil, ErrNotRanked
+	}
+
+	result["user"] = me
+	for _, r := range rows {
+		if r["position"].(int64) < me["position"].(int64) {
+			result["above"] = r
+		} else if r["position"].(int64) > me["position"].(int64) {
+			result["below"] = r
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+func GetUserRole(userID int) (string, error) {
+	var role string
+	err := DB.QueryRow(context.Background(), `SELECT role FROM users WHERE id = $1`, userID).Scan(&role)
+	return role, err
+}
+
+func SetUserRole(userID int, role string) error {
+	tag, err := DB.Exec(context.Background(), `UPDATE users SET role = $1 WHERE id = $2`, role, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("usuario no encontrado")
+	}
+	return nil
+}
+
+// GetTournamentRole devuelve el rol del usuario en el torneo: "owner" si es el creador,
+// "organizer" o "referee" si forma parte del staff, o "" si no tiene ninguno.
+func GetTournamentRole(tournamentID, userID int) (string, error) {
+	var role string
+	err := DB.QueryRow(context.Background(), `
+        SELECT CASE
+            WHEN t.created_by_user_id = $2 THEN 'owner'
+            ELSE COALESCE(s.role, '')
+        END
+        FROM tournaments t
+        LEFT JOIN tournament_staff s ON s.tournament_id = t.id AND s.user_id = $2
+        WHERE t.id = $1
+    `, tournamentID, userID).Scan(&role)
+	return role, err
+}
+
+// GetMatchPlayers devuelve el torneo y los jugadores de un match (0 si el hueco está vacío)
+func GetMatchPlayers(matchID int) (tournamentID, player1ID, player2ID int, err error) {
+	err = DB.QueryRow(context.Background(), `
+        SELECT tournament_id, COALESCE(player1_id, 0), COALESCE(player2_id, 0)
+        FROM matches
+        WHERE id = $1
+    `, matchID).Scan(&tournamentID, &player1ID, &player2ID)
+	return
+}
+
+func GetTournamentStaff(tournamentID int) ([]map[string]interface{}, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT s.user_id, u.username, u.avatar_url, s.role, s.created_at
+        FROM tournament_staff s
+        JOIN users u ON u.id = s.user_id
+        WHERE s.tournament_id = $1
+        ORDER BY s.role, u.username
+    `, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	staff := []map[string]interface{}{}
+	for rows.Next() {
+		var userID int
+		var username, role string
+		var avatarURL *string
+		var createdAt time.Time
+
+		if err := rows.Scan(&userID, &username, &avatarURL, &role, &createdAt); err != nil {
+			return nil, err
+		}
+
+		staff = append(staff, map[string]interface{}{
+			"user_id":    userID,
+			"username":   username,
+			"avatar_url": nullString(avatarURL),
+			"role":       role,
+			"created_at": createdAt,
+		})
+	}
+
+	return staff, rows.Err()
+}
+
+// AddTournamentStaff asigna (o cambia) el rol de staff de un usuario en un torneo
+func AddTournamentStaff(tournamentID, userID int, role string, grantedBy int) error {
+	_, err := DB.Exec(context.Background(), `
+        INSERT INTO tournament_staff (tournament_id, user_id, role, granted_by_user_id)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (tournament_id, user_id)
+        DO UPDATE SET role = EXCLUDED.role, granted_by_user_id = EXCLUDED.granted_by_user_id
+    `, tournamentID, userID, role, grantedBy)
+	return err
+}
+
+func RemoveTournamentStaff(tournamentID, userID int) error {
+	tag, err := DB.Exec(context.Background(), `
+        DELETE FROM tournament_staff WHERE tournament_id = $1 AND user_id = $2
+    `, tournamentID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("el usuario no forma parte del staff del torneo")
+	}
+	return nil
+}
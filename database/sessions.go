@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token inválido o caducado")
+	ErrRefreshTokenReused  = errors.New("refresh token reutilizado")
+
+	ErrUserNotFound   = errors.New("usuario no encontrado")
+	ErrUserBanned     = errors.New("usuario suspendido")
+	ErrSessionRevoked = errors.New("sesión revocada")
+)
+
+// RefreshToken es el registro de un refresh token; el token en sí solo lo tiene el
+// cliente, aquí se guarda su hash
+type RefreshToken struct {
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	UserAgent string
+	IP        string
+	TTL       time.Duration
+}
+
+func CreateRefreshToken(t RefreshToken) error {
+	_, err := DB.Exec(context.Background(), `
+        INSERT INTO refresh_tokens (user_id, family_id, token_hash, user_agent, ip, expires_at)
+        VALUES ($1, $2, $3, $4, $5, NOW() + $6::interval)
+    `, t.UserID, t.FamilyID, t.TokenHash, t.UserAgent, t.IP, t.TTL.String())
+	return err
+}
+
+// Tiempo durante el que un token recién rotado se sigue aceptando. Varias pestañas que
+// renuevan a la vez envían la misma cookie; la que llega después no es un robo.
+const refreshRotationGrace = 10 * time.Second
+
+// RotateRefreshToken canjea el token con hash oldHash por next, que hereda su familia, y
+// devuelve el usuario. Un token ya rotado que se vuelve a presentar pasado
+// refreshRotationGrace indica que alguien lo ha robado: se revoca toda la familia y se
+// devuelve ErrRefreshTokenReused.
+func RotateRefreshToken(oldHash string, next RefreshToken) (int, error) {
+	ctx := context.Background()
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id, userID int
+	var familyID string
+	var expired, recentlyRotated bool
+	var rotatedAt, revokedAt *time.Time
+	err = tx.QueryRow(ctx, `
+        SELECT id, user_id, family_id, expires_at < NOW(), rotated_at, revoked_at,
+               COALESCE(rotated_at > NOW() - $2::interval, FALSE)
+        FROM refresh_tokens
+        WHERE token_hash = $1
+        FOR UPDATE
+    `, oldHash, refreshRotationGrace.String()).Scan(&id, &userID, &familyID, &expired, &rotatedAt, &revokedAt, &recentlyRotated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if rotatedAt != nil && revokedAt == nil && !recentlyRotated {
+		if _, err := tx.Exec(ctx, `
+            UPDATE refresh_tokens SET revoked_at = NOW()
+            WHERE family_id = $1 AND revoked_at IS NULL
+        `, familyID); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+		return userID, ErrRefreshTokenReused
+	}
+	if revokedAt != nil || expired {
+		return 0, ErrRefreshTokenInvalid
+	}
+
+	// Dentro del periodo de gracia el token ya tiene sucesor y se emite otro en la misma
+	// familia; rotated_at no se actualiza para que la gracia no se alargue
+	if rotatedAt == nil {
+		if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET rotated_at = NOW() WHERE id = $1`, id); err != nil {
+			return 0, err
+		}
+	}
+	_, err = tx.Exec(ctx, `
+        INSERT INTO refresh_tokens (user_id, family_id, token_hash, user_agent, ip, expires_at)
+        VALUES ($1, $2, $3, $4, $5, NOW() + $6::interval)
+    `, userID, familyID, next.TokenHash, next.UserAgent, next.IP, next.TTL.String())
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit(ctx)
+}
+
+// RevokeRefreshFamily cierra la sesión a la que pertenece el token
+func RevokeRefreshFamily(tokenHash string) error {
+	_, err := DB.Exec(context.Background(), `
+        UPDATE refresh_tokens SET revoked_at = NOW()
+        WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)
+          AND revoked_at IS NULL
+    `, tokenHash)
+	return err
+}
+
+// RevokeUserSessions cierra todas las sesiones del usuario, incluidos los tokens de
+// acceso ya emitidos
+func RevokeUserSessions(userID int) error {
+	ctx := context.Background()
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+        UPDATE refresh_tokens SET revoked_at = NOW()
+        WHERE user_id = $1 AND revoked_at IS NULL
+    `, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET sessions_revoked_at = NOW() WHERE id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteExpiredRefreshTokens borra los tokens caducados hace más de un día; se guardan
+// un tiempo para poder detectar su reutilización
+func DeleteExpiredRefreshTokens() error {
+	_, err := DB.Exec(context.Background(), `
+        DELETE FROM refresh_tokens WHERE expires_at < NOW() - INTERVAL '1 day'
+    `)
+	return err
+}
+
+// CheckUserActive comprueba que el usuario puede seguir usando un token emitido en
+// issuedAt: que exista, no tenga una sanción global vigente, no esté borrado y no haya
+// cerrado todas sus sesiones después. El iat del token solo tiene precisión de segundos,
+// así que la fecha de cierre se compara también truncada a segundos.
+func CheckUserActive(userID int, issuedAt time.Time) error {
+	var banned, deleted, revoked bool
+	err := DB.QueryRow(context.Background(), `
+        SELECT EXISTS (
+                   SELECT 1 FROM bans b
+                   WHERE b.user_id = u.id AND b.tournament_id IS NULL AND `+activeBan+`
+               ),
+               u.deleted_at IS NOT NULL,
+               COALESCE(date_trunc('second', u.sessions_revoked_at) > to_timestamp($2)::timestamp, FALSE)
+        FROM users u WHERE u.id = $1
+    `, userID, issuedAt.Unix()).Scan(&banned, &deleted, &revoked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case deleted:
+		return ErrUserNotFound
+	case banned:
+		return ErrUserBanned
+	case revoked:
+		return ErrSessionRevoked
+	}
+	return nil
+}
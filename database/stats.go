@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Solo cuentan para estadísticas los matches completados con dos jugadores reales
+// (los pases por BYE no son victorias).
+const playedMatchCondition = `
+    m.status = 'completed' AND m.winner_id IS NOT NULL
+    AND m.player1_id IS NOT NULL AND m.player2_id IS NOT NULL
+`
+
+func winRate(wins, played int) float64 {
+	if played == 0 {
+		return 0
+	}
+	return float64(wins) / float64(played)
+}
+
+// GetUserStats calcula las estadísticas competitivas de un usuario
+func GetUserStats(userID int) (map[string]interface{}, error) {
+	ctx := context.Background()
+
+	// 1. Balance global de matches
+	var played, wins int
+	err := DB.QueryRow(ctx, `
+        SELECT
+            COUNT(*),
+            COUNT(*) FILTER (WHERE m.winner_id = $1)
+        FROM matches m
+        WHERE (m.player1_id = $1 OR m.player2_id = $1) AND `+playedMatchCondition,
+		userID).Scan(&played, &wins)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Balance por juego
+	rows, err := DB.Query(ctx, `
+        SELECT
+            t.game,
+            COUNT(*),
+            COUNT(*) FILTER (WHERE m.winner_id = $1)
+        FROM matches m
+        JOIN tournaments t ON t.id = m.tournament_id
+        WHERE (m.player1_id = $1 OR m.player2_id = $1) AND `+playedMatchCondition+`
+        GROUP BY t.game
+        ORDER BY COUNT(*) DESC, t.game
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perGame := []map[string]interface{}{}
+	for rows.Next() {
+		var game string
+		var gamePlayed, gameWins int
+		if err := rows.Scan(&game, &gamePlayed, &gameWins); err != nil {
+			return nil, err
+		}
+		perGame = append(perGame, map[string]interface{}{
+			"game":     game,
+			"played":   gamePlayed,
+			"wins":     gameWins,
+			"losses":   gamePlayed - gameWins,
+			"win_rate": winRate(gameWins, gamePlayed),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 3. Torneos jugados, ganados, podios (según la clasificación final) y ronda media alcanzada
+	var tournamentsPlayed, tournamentsWon, podiums int
+	var avgRound *float64
+	err = DB.QueryRow(ctx, `
+        WITH reached AS (
+            SELECT m.tournament_id, MAX(m.round) AS round
+            FROM matches m
+            WHERE m.player1_id = $1 OR m.player2_id = $1
+            GROUP BY m.tournament_id
+        )
+        SELECT
+            (SELECT COUNT(*) FROM participants WHERE user_id = $1),
+            (SELECT COUNT(*) FROM tournaments WHERE champion_id = $1),
+            (SELECT COUNT(*) FROM placements WHERE user_id = $1 AND placement <= 3),
+            (SELECT AVG(round)::float8 FROM reached)
+    `, userID).Scan(&tournamentsPlayed, &tournamentsWon, &podiums, &avgRound)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Rachas de victorias (gaps and islands sobre los matches ordenados)
+	var currentStreak, longestStreak int
+	err = DB.QueryRow(ctx, `
+        WITH ordered AS (
+            SELECT
+                (m.winner_id = $1) AS won,
+                ROW_NUMBER() OVER (ORDER BY m.played_at NULLS FIRST, m.id) AS rn
+            FROM matches m
+            WHERE (m.player1_id = $1 OR m.player2_id = $1) AND `+playedMatchCondition+`
+        ),
+        grouped AS (
+            SELECT won, rn, rn - ROW_NUMBER() OVER (PARTITION BY won ORDER BY rn) AS grp
+            FROM ordered
+        ),
+        streaks AS (
+            SELECT won, COUNT(*) AS length, MAX(rn) AS last_rn
+            FROM grouped
+            GROUP BY won, grp
+        )
+        SELECT
+            COALESCE((SELECT length FROM streaks
+                      WHERE won AND last_rn = (SELECT MAX(rn) FROM ordered)), 0),
+            COALESCE((SELECT MAX(length) FROM streaks WHERE won), 0)
+    `, userID).Scan(&currentStreak, &longestStreak)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"user_id":            userID,
+		"matches_played":     played,
+		"wins":               wins,
+		"losses":             played - wins,
+		"win_rate":           winRate(wins, played),
+		"per_game":           perGame,
+		"tournaments_played": tournamentsPlayed,
+		"tournaments_won":    tournamentsWon,
+		"podium_finishes":    podiums,
+		"current_win_streak": currentStreak,
+		"longest_win_streak": longestStreak,
+		"average_round":      avgRound,
+	}, nil
+}
+
+// GetHeadToHead devuelve el historial de enfrentamientos entre dos usuarios
+func GetHeadToHead(userA, userB int) (map[string]interface{}, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT m.id, m.tournament_id, t.name, t.game, m.round, m.played_at, m.winner_id
+        FROM matches m
+        JOIN tournaments t ON t.id = m.tournament_id
+        WHERE ((m.player1_id = $1 AND m.player2_id = $2) OR (m.player1_id = $2 AND m.player2_id = $1))
+          AND `+playedMatchCondition+`
+        ORDER BY m.played_at DESC NULLS LAST, m.id DESC
+    `, userA, userB)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var winsA, winsB int
+	matches := []map[string]interface{}{}
+	for rows.Next() {
+		var matchID, tournamentID, round, winnerID int
+		var tournamentName, game string
+		var playedAt *time.Time
+
+		if err := rows.Scan(&matchID, &tournamentID, &tournamentName, &game, &round, &playedAt, &winnerID); err != nil {
+			return nil, err
+		}
+
+		if winnerID == userA {
+			winsA++
+		} else {
+			winsB++
+		}
+
+		matches = append(matches, map[string]interface{}{
+			"match_id":        matchID,
+			"tournament_id":   tournamentID,
+			"tournament_name": tournamentName,
+			"game":            game,
+			"round":           round,
+			"played_at":       playedAt,
+			"winner_id":       winnerID,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"user_a":  map[string]interface{}{"id": userA, "wins": winsA},
+		"user_b":  map[string]interface{}{"id": userB, "wins": winsB},
+		"total":   len(matches),
+		"matches": matches,
+	}, nil
+}
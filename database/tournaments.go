@@ -198,3 +198,33 @@ func LeaveTournament(tournamentID, userID int) error {
 	`, tournamentID, userID)
 	return err
 }
+
+// GetTournamentDiscordWebhook devuelve la URL del webhook de Discord del torneo
+// ("" si no tiene)
+func GetTournamentDiscordWebhook(tournamentID int) (string, error) {
+	var url *string
+	err := DB.QueryRow(context.Background(), `
+		SELECT discord_webhook_url FROM tournaments WHERE id = $1
+	`, tournamentID).Scan(&url)
+	if err != nil || url == nil {
+		return "", err
+	}
+	return *url, nil
+}
+
+// SetTournamentDiscordWebhook asigna o, con nil, quita el webhook de Discord del torneo
+func SetTournamentDiscordWebhook(tournamentID int, url *string) error {
+	_, err := DB.Exec(context.Background(), `
+		UPDATE tournaments SET discord_webhook_url = $1 WHERE id = $2
+	`, url, tournamentID)
+	return err
+}
+
+// HasBracket indica si ya se ha generado el bracket del torneo
+func HasBracket(tournamentID int) (bool, error) {
+	var exists bool
+	err := DB.QueryRow(context.Background(), `
+		SELECT EXISTS (SELECT 1 FROM matches WHERE tournament_id = $1)
+	`, tournamentID).Scan(&exists)
+	return exists, err
+}
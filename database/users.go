@@ -6,40 +6,39 @@ import (
 	"torneos/models"
 )
 
-func GetAllUsers() ([]models.User, error) {
-	rows, err := DB.Query(context.Background(), "SELECT id, username, email, oauth_provider, oauth_id, avatar_url, created_at FROM users")
+// GetUsers devuelve una página de usuarios con sus datos de cuenta, para administración,
+// y el total
+func GetUsers(limit, offset int) ([]models.PrivateUser, int, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT id, role, username, COALESCE(email, ''), COALESCE(avatar_url, ''), created_at, twitch, youtube,
+               match_history_visibility, socials_visibility, stats_visibility, COUNT(*) OVER ()
+        FROM users
+        ORDER BY id
+        LIMIT $1 OFFSET $2
+    `, limit, offset)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var users []models.User
-
+	users := []models.PrivateUser{}
+	total := 0
 	for rows.Next() {
-		var u models.User
-		err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.OAuthProvider, &u.OAuthID, &u.AvatarURL, &u.CreatedAt)
+		var u models.PrivateUser
+		err := rows.Scan(&u.ID, &u.Role, &u.Username, &u.Email, &u.Avatar, &u.CreatedAt, &u.Twitch, &u.YouTube,
+			&u.Visibility.MatchHistory, &u.Visibility.Socials, &u.Visibility.Stats, &total)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		users = append(users, u)
 	}
 
-	return users, nil
+	return users, total, rows.Err()
 }
 
-// Buscar por oauth_provider + oauth_id
+// Buscar por proveedor + ID en el proveedor (cualquiera de las cuentas vinculadas)
 func FindUserByOAuth(provider, oauthID string) (*models.User, error) {
-	var user models.User
-	err := DB.QueryRow(context.Background(),
-		`SELECT id, username, email, oauth_provider, oauth_id, avatar_url, created_at
-		 FROM users WHERE oauth_provider=$1 AND oauth_id=$2`,
-		provider, oauthID).
-		Scan(&user.ID, &user.Username, &user.Email, &user.OAuthProvider, &user.OAuthID, &user.AvatarURL, &user.CreatedAt)
-
-	if err != nil {
-		return nil, err
-	}
-	return &user, nil
+	return FindUserByIdentity(provider, oauthID)
 }
 
 func CreateUser(u *models.User) (*models.User, error) {
@@ -59,9 +58,12 @@ func CreateUser(u *models.User) (*models.User, error) {
 func GetUserByID(id int) (*models.User, error) {
 	var user models.User
 	err := DB.QueryRow(context.Background(),
-		`SELECT id, username, email, oauth_provider, oauth_id, avatar_url, created_at, twitch, youtube
+		`SELECT id, username, COALESCE(email, ''), COALESCE(oauth_provider, ''), COALESCE(oauth_id, ''),
+		        COALESCE(avatar_url, ''), created_at, twitch, youtube,
+		        match_history_visibility, socials_visibility, stats_visibility
 		 FROM users WHERE id=$1`, id).
-		Scan(&user.ID, &user.Username, &user.Email, &user.OAuthProvider, &user.OAuthID, &user.AvatarURL, &user.CreatedAt, &user.Twitch, &user.YouTube)
+		Scan(&user.ID, &user.Username, &user.Email, &user.OAuthProvider, &user.OAuthID, &user.AvatarURL, &user.CreatedAt, &user.Twitch, &user.YouTube,
+			&user.Visibility.MatchHistory, &user.Visibility.Socials, &user.Visibility.Stats)
 
 	if err != nil {
 		return nil, err
@@ -69,44 +71,13 @@ func GetUserByID(id int) (*models.User, error) {
 	return &user, nil
 }
 
-func GetRankingTop(limit int) ([]map[string]interface{}, error) {
-	rows, err := DB.Query(context.Background(), `
-        SELECT id, username, points
-        FROM users
-        ORDER BY points DESC
-        LIMIT $1
-    `, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var ranking []map[string]interface{}
-
-	for rows.Next() {
-		var id int
-		var username string
-		var points int
-
-		if err := rows.Scan(&id, &username, &points); err != nil {
-			return nil, err
-		}
-
-		ranking = append(ranking, map[string]interface{}{
-			"id":       id,
-			"username": username,
-			"points":   points,
-		})
-	}
-
-	return ranking, nil
-}
-
 func GetUserTournamentHistory(userID int) ([]map[string]interface{}, error) {
 	rows, err := DB.Query(context.Background(), `
-        SELECT t.id, t.name, t.game, t.start_time, t.is_finished
+        SELECT t.id, t.name, t.game, t.start_time, t.is_finished,
+            pl.placement, pl.placement_max
         FROM participants p
         JOIN tournaments t ON p.tournament_id = t.id
+        LEFT JOIN placements pl ON pl.tournament_id = t.id AND pl.user_id = p.user_id
         WHERE p.user_id = $1
         ORDER BY t.start_time DESC
     `, userID)
@@ -124,17 +95,26 @@ func GetUserTournamentHistory(userID int) ([]map[string]interface{}, error) {
 		var game string
 		var startTime time.Time
 		var isFinished bool
+		var placement, placementMax *int
 
-		if err := rows.Scan(&tournamentID, &name, &game, &startTime, &isFinished); err != nil {
+		if err := rows.Scan(&tournamentID, &name, &game, &startTime, &isFinished, &placement, &placementMax); err != nil {
 			return nil, err
 		}
 
+		var label interface{}
+		if placement != nil && placementMax != nil {
+			label = placementLabel(*placement, *placementMax)
+		}
+
 		history = append(history, map[string]interface{}{
-			"tournament_id": tournamentID,
-			"name":          name,
-			"game":          game,
-			"start_time":    startTime,
-			"is_finished":   isFinished,
+			"tournament_id":   tournamentID,
+			"name":            name,
+			"game":            game,
+			"start_time":      startTime,
+			"is_finished":     isFinished,
+			"placement":       nullInt(placement),
+			"placement_max":   nullInt(placementMax),
+			"placement_label": label,
 		})
 	}
 
@@ -191,3 +171,21 @@ func GetUserMatches(userID int) ([]map[string]interface{}, error) {
 
 	return matches, nil
 }
+
+// SetUserVisibility cambia la visibilidad de las secciones del perfil; los campos
+// vacíos se dejan como estaban
+func SetUserVisibility(userID int, v models.UserVisibility) (*models.UserVisibility, error) {
+	var updated models.UserVisibility
+	err := DB.QueryRow(context.Background(), `
+        UPDATE users
+        SET match_history_visibility = COALESCE(NULLIF($2, ''), match_history_visibility),
+            socials_visibility = COALESCE(NULLIF($3, ''), socials_visibility),
+            stats_visibility = COALESCE(NULLIF($4, ''), stats_visibility)
+        WHERE id = $1
+        RETURNING match_history_visibility, socials_visibility, stats_visibility
+    `, userID, v.MatchHistory, v.Socials, v.Stats).Scan(&updated.MatchHistory, &updated.Socials, &updated.Stats)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	"torneos/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func CreateWebhook(w *models.Webhook) error {
+	return DB.QueryRow(context.Background(), `
+        INSERT INTO webhooks (tournament_id, url, secret, events, created_by_user_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, active, created_at
+    `, w.TournamentID, w.URL, w.Secret, w.Events, w.CreatedByUserID).Scan(&w.ID, &w.Active, &w.CreatedAt)
+}
+
+// GetTournamentWebhooks devuelve los webhooks del torneo sin su secreto
+func GetTournamentWebhooks(tournamentID int) ([]models.Webhook, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT id, tournament_id, url, events, active, created_by_user_id, created_at
+        FROM webhooks
+        WHERE tournament_id = $1
+        ORDER BY id
+    `, tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var w models.Webhook
+		err := rows.Scan(&w.ID, &w.TournamentID, &w.URL, &w.Events, &w.Active, &w.CreatedByUserID, &w.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func DeleteWebhook(tournamentID, webhookID int) error {
+	tag, err := DB.Exec(context.Background(), `
+        DELETE FROM webhooks WHERE id = $1 AND tournament_id = $2
+    `, webhookID, tournamentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("webhook no encontrado")
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries crea una entrega pendiente por cada webhook activo del
+// torneo suscrito al evento y devuelve cuántas se han creado
+func EnqueueWebhookDeliveries(tournamentID int, eventType string, payload []byte) (int64, error) {
+	tag, err := DB.Exec(context.Background(), `
+        INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+        SELECT id, $2, $3
+        FROM webhooks
+        WHERE tournament_id = $1 AND active AND $2 = ANY(events)
+    `, tournamentID, eventType, payload)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimWebhookDeliveries reserva hasta limit entregas pendientes que ya toca enviar.
+// La reserva dura lease: si la instancia cae a mitad del envío, otra lo reintentará.
+// SKIP LOCKED evita que dos instancias reclamen la misma entrega.
+func ClaimWebhookDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	rows, err := DB.Query(context.Background(), `
+        WITH due AS (
+            SELECT id FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        UPDATE webhook_deliveries d
+        SET next_attempt_at = NOW() + $2::interval
+        FROM due, webhooks w
+        WHERE d.id = due.id AND w.id = d.webhook_id
+        RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.attempts, w.url, w.secret
+    `, limit, lease.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func MarkWebhookDelivered(deliveryID int64, statusCode int) error {
+	_, err := DB.Exec(context.Background(), `
+        UPDATE webhook_deliveries
+        SET status = 'delivered', attempts = attempts + 1, last_status_code = $2,
+            last_error = NULL, delivered_at = NOW()
+        WHERE id = $1
+    `, deliveryID, statusCode)
+	return err
+}
+
+// MarkWebhookAttemptFailed registra un intento fallido. Con nextAttempt nil no se
+// reintenta más y la entrega queda como failed.
+func MarkWebhookAttemptFailed(deliveryID int64, statusCode *int, errMsg string, nextAttempt *time.Time) error {
+	_, err := DB.Exec(context.Background(), `
+        UPDATE webhook_deliveries
+        SET attempts = attempts + 1,
+            last_status_code = $2,
+            last_error = $3,
+            status = CASE WHEN $4::timestamp IS NULL THEN 'failed' ELSE 'pending' END,
+            next_attempt_at = COALESCE($4::timestamp, next_attempt_at)
+        WHERE id = $1
+    `, deliveryID, statusCode, errMsg, nextAttempt)
+	return err
+}
+
+const webhookDeliveryColumns = `
+    d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+    d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetWebhookDeliveries devuelve el registro de entregas de un webhook del torneo,
+// las más recientes primero
+func GetWebhookDeliveries(tournamentID, webhookID, limit, offset int) ([]models.WebhookDelivery, error) {
+	rows, err := DB.Query(context.Background(), `
+        SELECT `+webhookDeliveryColumns+`
+        FROM webhook_deliveries d
+        JOIN webhooks w ON w.id = d.webhook_id
+        WHERE d.webhook_id = $1 AND w.tournament_id = $2
+        ORDER BY d.created_at DESC, d.id DESC
+        LIMIT $3 OFFSET $4
+    `, webhookID, tournamentID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RedeliverWebhook encola una entrega nueva con el mismo payload que una anterior.
+// La original se conserva en el registro.
+func RedeliverWebhook(tournamentID, webhookID int, deliveryID int64) (*models.WebhookDelivery, error) {
+	row := DB.QueryRow(context.Background(), `
+        WITH d AS (
+            INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+            SELECT d.webhook_id, d.event_type, d.payload
+            FROM webhook_deliveries d
+            JOIN webhooks w ON w.id = d.webhook_id
+            WHERE d.id = $1 AND d.webhook_id = $2 AND w.tournament_id = $3
+            RETURNING *
+        )
+        SELECT `+webhookDeliveryColumns+` FROM d
+    `, deliveryID, webhookID, tournamentID)
+
+	d, err := scanWebhookDelivery(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.New("entrega no encontrada")
+	}
+	return d, err
+}
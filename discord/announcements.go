@@ -0,0 +1,235 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"torneos/database"
+	"torneos/models"
+	"torneos/realtime"
+)
+
+var (
+	announcements = make(chan realtime.Event, 256)
+	frontendURL   string
+	startOnce     sync.Once
+)
+
+// Start registra los anuncios de Discord como listener de los eventos de tiempo real.
+// frontendURL se usa para enlazar los embeds con la página del torneo.
+func Start(frontend string) {
+	startOnce.Do(func() {
+		frontendURL = strings.TrimSuffix(frontend, "/")
+		realtime.AddListener(handleEvent)
+		go run()
+	})
+}
+
+// handleEvent no bloquea: las consultas y el envío se hacen en la goroutine de run
+func handleEvent(event realtime.Event) {
+	switch event.Type {
+	case realtime.EventBracketGenerated, realtime.EventMatchResult, realtime.EventTournamentFinished:
+	default:
+		return
+	}
+
+	select {
+	case announcements <- event:
+	default:
+		log.Printf("Cola de anuncios de Discord llena, evento %s descartado", event.Type)
+	}
+}
+
+func run() {
+	for event := range announcements {
+		webhookURL, err := database.GetTournamentDiscordWebhook(event.TournamentID)
+		if err != nil {
+			log.Printf("Error obteniendo el webhook de Discord del torneo %d: %v", event.TournamentID, err)
+			continue
+		}
+		if webhookURL == "" {
+			continue
+		}
+
+		tournament, err := database.GetTournamentByID(event.TournamentID)
+		if err != nil {
+			log.Printf("Error obteniendo el torneo %d para Discord: %v", event.TournamentID, err)
+			continue
+		}
+
+		var embed *Embed
+		switch payload := event.Payload.(type) {
+		case realtime.BracketGeneratedPayload:
+			embed = bracketEmbed(tournament, payload)
+		case realtime.MatchResultPayload:
+			embed = matchResultEmbed(tournament, payload)
+		case realtime.TournamentFinishedPayload:
+			embed = championEmbed(tournament, payload)
+		}
+		if embed != nil {
+			Post(webhookURL, WebhookMessage{Embeds: []Embed{*embed}})
+		}
+	}
+}
+
+// AnnounceRegistration publica que el torneo tiene las inscripciones abiertas
+func AnnounceRegistration(tournamentID int) error {
+	webhookURL, err := database.GetTournamentDiscordWebhook(tournamentID)
+	if err != nil || webhookURL == "" {
+		return err
+	}
+
+	t, err := database.GetTournamentByID(tournamentID)
+	if err != nil {
+		return err
+	}
+
+	embed := Embed{
+		Title:       "Inscripciones abiertas: " + t.Name,
+		Description: t.Description,
+		URL:         tournamentLink(t.ID),
+		Color:       ColorInfo,
+		Fields: []EmbedField{
+			{Name: "Juego", Value: orDash(t.Game), Inline: true},
+			{Name: "Plataforma", Value: orDash(t.Platform), Inline: true},
+			{Name: "Plazas", Value: fmt.Sprintf("%d", t.MaxParticipants), Inline: true},
+			{Name: "Inicio", Value: fmt.Sprintf("<t:%d:F>", t.StartTime.Unix())},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if t.BannerURL != "" {
+		embed.Image = &EmbedImage{URL: t.BannerURL}
+	}
+
+	Post(webhookURL, WebhookMessage{Embeds: []Embed{embed}})
+	return nil
+}
+
+// bracketEmbed lista los emparejamientos de la primera ronda
+func bracketEmbed(t *models.Tournament, payload realtime.BracketGeneratedPayload) *Embed {
+	firstRound := 0
+	for _, m := range payload.Matches {
+		if firstRound == 0 || m.Round < firstRound {
+			firstRound = m.Round
+		}
+	}
+
+	var lines []string
+	for _, m := range payload.Matches {
+		if m.Round != firstRound {
+			continue
+		}
+		switch {
+		case m.Player1 != nil && m.Player2 != nil:
+			lines = append(lines, fmt.Sprintf("**%s** vs **%s**", m.Player1.Username, m.Player2.Username))
+		case m.Player1 != nil:
+			lines = append(lines, fmt.Sprintf("**%s** pasa directamente", m.Player1.Username))
+		case m.Player2 != nil:
+			lines = append(lines, fmt.Sprintf("**%s** pasa directamente", m.Player2.Username))
+		}
+	}
+
+	return &Embed{
+		Title:       "Bracket generado: " + t.Name,
+		Description: truncate("**Ronda 1**\n" + strings.Join(lines, "\n")),
+		URL:         tournamentLink(t.ID),
+		Color:       ColorInfo,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// matchResultEmbed muestra el ganador del match y su próximo rival. Los matches no
+// guardan marcador, solo el ganador.
+func matchResultEmbed(t *models.Tournament, payload realtime.MatchResultPayload) *Embed {
+	m := payload.Match
+	if m.Winner == nil {
+		return nil
+	}
+
+	loser := m.Player1
+	if loser != nil && loser.ID == m.Winner.ID {
+		loser = m.Player2
+	}
+
+	description := fmt.Sprintf("**%s** gana", m.Winner.Username)
+	if loser != nil {
+		description = fmt.Sprintf("**%s** gana a **%s**", m.Winner.Username, loser.Username)
+	}
+
+	embed := &Embed{
+		Title:       fmt.Sprintf("%s · Ronda %d", t.Name, m.Round),
+		Description: description,
+		URL:         tournamentLink(t.ID),
+		Color:       ColorSuccess,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if m.ScreenshotURL != nil && strings.HasPrefix(*m.ScreenshotURL, "http") {
+		embed.Thumbnail = &EmbedImage{URL: *m.ScreenshotURL}
+	}
+
+	next, err := database.GetNextMatchForPlayer(t.ID, m.Winner.ID)
+	if err != nil {
+		log.Printf("Error obteniendo el próximo match de %d: %v", m.Winner.ID, err)
+		return embed
+	}
+	if next != nil {
+		opponent := "por determinar"
+		if next.Player1 != nil && next.Player1.ID != m.Winner.ID {
+			opponent = next.Player1.Username
+		} else if next.Player2 != nil && next.Player2.ID != m.Winner.ID {
+			opponent = next.Player2.Username
+		}
+		embed.Fields = append(embed.Fields, EmbedField{
+			Name:  fmt.Sprintf("Próximo rival (ronda %d)", next.Round),
+			Value: opponent,
+		})
+	}
+
+	return embed
+}
+
+func championEmbed(t *models.Tournament, payload realtime.TournamentFinishedPayload) *Embed {
+	if payload.Champion == nil {
+		return nil
+	}
+
+	embed := &Embed{
+		Title:       fmt.Sprintf("🏆 %s es campeón de %s", payload.Champion.Username, t.Name),
+		URL:         tournamentLink(t.ID),
+		Color:       ColorGold,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Description: "¡Enhorabuena!",
+	}
+	if payload.Champion.AvatarURL != "" {
+		embed.Thumbnail = &EmbedImage{URL: payload.Champion.AvatarURL}
+	}
+	if payload.RunnerUp != nil {
+		embed.Fields = append(embed.Fields, EmbedField{Name: "Subcampeón", Value: payload.RunnerUp.Username})
+	}
+
+	return embed
+}
+
+func tournamentLink(tournamentID int) string {
+	if frontendURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/tournaments/%d", frontendURL, tournamentID)
+}
+
+func truncate(s string) string {
+	if r := []rune(s); len(r) > maxDescription {
+		return string(r[:maxDescription-1]) + "…"
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Tipos de opción de los comandos de aplicación
+const (
+	optionInteger = 4
+	optionUser    = 6
+)
+
+type CommandOptionDefinition struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type CommandDefinition struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Options     []CommandOptionDefinition `json:"options,omitempty"`
+}
+
+var tournamentOption = CommandOptionDefinition{
+	Type: optionInteger, Name: "tournament", Description: "ID del torneo", Required: true,
+}
+
+// Commands son los comandos que atiende InteractionsHandler
+var Commands = []CommandDefinition{
+	{Name: "join", Description: "Inscribirse en un torneo", Options: []CommandOptionDefinition{tournamentOption}},
+	{Name: "checkin", Description: "Hacer check-in en un torneo", Options: []CommandOptionDefinition{tournamentOption}},
+	{Name: "report", Description: "Reportar el ganador de un match", Options: []CommandOptionDefinition{
+		{Type: optionInteger, Name: "match", Description: "ID del match", Required: true},
+		{Type: optionUser, Name: "winner", Description: "Ganador del match", Required: true},
+	}},
+	{Name: "next", Description: "Ver mi próximo match", Options: []CommandOptionDefinition{
+		{Type: optionInteger, Name: "tournament", Description: "ID del torneo (opcional)"},
+	}},
+	{Name: "bracket", Description: "Ver el bracket de un torneo", Options: []CommandOptionDefinition{tournamentOption}},
+}
+
+// RegisterCommands sustituye los comandos globales de la aplicación por Commands.
+// apiURL es la base de la API de Discord (https://discord.com/api/v10).
+func RegisterCommands(apiURL, applicationID, botToken string) error {
+	body, err := json.Marshal(Commands)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut,
+		fmt.Sprintf("%s/applications/%s/commands", apiURL, applicationID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bot "+botToken)
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return fmt.Errorf("discord respondió %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
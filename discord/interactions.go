@@ -0,0 +1,399 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"torneos/audit"
+	"torneos/auth"
+	"torneos/database"
+	"torneos/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Tipos de interacción y de respuesta de la API de Discord
+const (
+	interactionPing               = 1
+	interactionApplicationCommand = 2
+
+	responsePong           = 1
+	responseChannelMessage = 4
+
+	// Mensaje visible solo para quien ejecuta el comando
+	flagEphemeral = 64
+)
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type commandOption struct {
+	Name  string          `json:"name"`
+	Type  int             `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type interaction struct {
+	Type int `json:"type"`
+	Data *struct {
+		Name    string          `json:"name"`
+		Options []commandOption `json:"options"`
+	} `json:"data"`
+	Member *struct {
+		User *discordUser `json:"user"`
+	} `json:"member"`
+	User *discordUser `json:"user"`
+}
+
+type interactionResponse struct {
+	Type int                      `json:"type"`
+	Data *interactionResponseData `json:"data,omitempty"`
+}
+
+type interactionResponseData struct {
+	Content string  `json:"content,omitempty"`
+	Embeds  []Embed `json:"embeds,omitempty"`
+	Flags   int     `json:"flags,omitempty"`
+}
+
+// InteractionsHandler atiende el endpoint de interacciones de Discord. publicKey es la
+// clave pública de la aplicación en hexadecimal; las peticiones sin firma válida se
+// rechazan con 401, como exige Discord.
+func InteractionsHandler(publicKey string) (gin.HandlerFunc, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("DISCORD_PUBLIC_KEY inválida")
+	}
+
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo inválido"})
+			return
+		}
+
+		if !verifySignature(key, c.GetHeader("X-Signature-Ed25519"), c.GetHeader("X-Signature-Timestamp"), body, time.Now()) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Firma inválida"})
+			return
+		}
+
+		var in interaction
+		if err := json.Unmarshal(body, &in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Interacción inválida"})
+			return
+		}
+
+		switch in.Type {
+		case interactionPing:
+			c.JSON(http.StatusOK, interactionResponse{Type: responsePong})
+		case interactionApplicationCommand:
+			c.JSON(http.StatusOK, interactionResponse{Type: responseChannelMessage, Data: handleCommand(in)})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tipo de interacción no soportado"})
+		}
+	}, nil
+}
+
+// Antigüedad máxima de X-Signature-Timestamp; una interacción firmada más antigua (o
+// con la fecha demasiado adelantada) se rechaza para que no pueda repetirse
+const signatureMaxAge = 5 * time.Minute
+
+// verifySignature comprueba la firma Ed25519 de Discord sobre timestamp + cuerpo y que
+// el timestamp (segundos Unix) no se aleje de now más de signatureMaxAge
+func verifySignature(key ed25519.PublicKey, signature, timestamp string, body []byte, now time.Time) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize || timestamp == "" {
+		return false
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return false
+	}
+	return ed25519.Verify(key, append([]byte(timestamp), body...), sig)
+}
+
+func (in interaction) discordUser() *discordUser {
+	if in.Member != nil && in.Member.User != nil {
+		return in.Member.User
+	}
+	return in.User
+}
+
+func (in interaction) option(name string) (json.RawMessage, bool) {
+	for _, o := range in.Data.Options {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (in interaction) intOption(name string) (int, bool) {
+	raw, ok := in.option(name)
+	if !ok {
+		return 0, false
+	}
+	var n int
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// userOption devuelve el ID de Discord de una opción de tipo usuario
+func (in interaction) userOption(name string) (string, bool) {
+	raw, ok := in.option(name)
+	if !ok {
+		return "", false
+	}
+	var id string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+func reply(content string) *interactionResponseData {
+	return &interactionResponseData{Content: content, Flags: flagEphemeral}
+}
+
+// handleCommand ejecuta el comando con el usuario vinculado a la cuenta de Discord.
+// Cada comando usa las mismas funciones que su endpoint REST.
+func handleCommand(in interaction) *interactionResponseData {
+	if in.Data == nil {
+		return reply("Comando inválido")
+	}
+
+	du := in.discordUser()
+	if du == nil {
+		return reply("No se pudo identificar tu usuario de Discord")
+	}
+	user, err := database.FindUserByOAuth("discord", du.ID)
+	if err != nil {
+		return reply("Tu cuenta de Discord no está vinculada. Inicia sesión en la web con Discord primero.")
+	}
+	if err := database.CheckUserActive(user.ID, time.Now()); err != nil {
+		return reply("Tu cuenta no puede usar los comandos: " + err.Error())
+	}
+
+	switch in.Data.Name {
+	case "join":
+		return commandJoin(in, user)
+	case "checkin":
+		return commandCheckIn(in, user)
+	case "report":
+		return commandReport(in, user)
+	case "next":
+		return commandNext(in, user)
+	case "bracket":
+		return commandBracket(in)
+	default:
+		return reply("Comando desconocido: " + in.Data.Name)
+	}
+}
+
+func commandJoin(in interaction, user *models.User) *interactionResponseData {
+	tournamentID, ok := in.intOption("tournament")
+	if !ok {
+		return reply("Indica el ID del torneo")
+	}
+
+	_, err := database.JoinTournament(user.ID, tournamentID)
+	if errors.Is(err, database.ErrBannedFromTournament) {
+		auditCommand(user, "tournament.join", http.StatusForbidden, "tournament", tournamentID, tournamentID)
+	}
+	if err != nil {
+		return reply("No se pudo completar la inscripción: " + err.Error())
+	}
+	auditCommand(user, "tournament.join", http.StatusCreated, "tournament", tournamentID, tournamentID)
+	return reply(fmt.Sprintf("Te has inscrito en el torneo #%d", tournamentID))
+}
+
+func commandCheckIn(in interaction, user *models.User) *interactionResponseData {
+	tournamentID, ok := in.intOption("tournament")
+	if !ok {
+		return reply("Indica el ID del torneo")
+	}
+
+	if err := database.CheckInParticipant(user.ID, tournamentID); err != nil {
+		return reply("No se pudo hacer el check-in: " + err.Error())
+	}
+	auditCommand(user, "tournament.checkin", http.StatusOK, "tournament", tournamentID, tournamentID)
+	return reply(fmt.Sprintf("Check-in realizado en el torneo #%d", tournamentID))
+}
+
+func commandReport(in interaction, user *models.User) *interactionResponseData {
+	matchID, ok := in.intOption("match")
+	if !ok {
+		return reply("Indica el ID del match")
+	}
+	winnerDiscordID, ok := in.userOption("winner")
+	if !ok {
+		return reply("Indica el ganador")
+	}
+
+	winner, err := database.FindUserByOAuth("discord", winnerDiscordID)
+	if err != nil {
+		return reply("El ganador no tiene su cuenta de Discord vinculada")
+	}
+
+	allowed, err := auth.CanReportMatch(user.ID, matchID)
+	if err != nil {
+		return matchLookupError(matchID, err)
+	}
+	before, err := database.GetMatchByID(matchID)
+	if err != nil {
+		return matchLookupError(matchID, err)
+	}
+	if !allowed {
+		auditCommand(user, "match.report", http.StatusForbidden, "match", matchID, before.TournamentID)
+		return reply("No tienes permiso para reportar este match")
+	}
+
+	if err := database.ReportMatchResult(matchID, user.ID, winner.ID); err != nil {
+		return reply("No se pudo reportar el resultado: " + err.Error())
+	}
+	after, err := database.PublishMatchResult(matchID, user.ID)
+	if err != nil {
+		log.Printf("Error publicando el resultado del match %d: %v", matchID, err)
+	}
+
+	entry := commandAuditEntry(user, "match.report", http.StatusOK, "match", matchID, before.TournamentID)
+	if after != nil {
+		entry.Before, entry.After = audit.Diff(before, after)
+	}
+	audit.Record(entry)
+
+	return &interactionResponseData{
+		Content: fmt.Sprintf("Resultado del match #%d registrado: gana **%s**", matchID, winner.Username),
+	}
+}
+
+// matchLookupError responde a un error al buscar un match: "no encontrado" solo si no
+// existe; cualquier otro error se registra y se responde con un mensaje genérico.
+func matchLookupError(matchID int, err error) *interactionResponseData {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return reply("Match no encontrado")
+	}
+	log.Printf("Error obteniendo el match %d: %v", matchID, err)
+	return reply("Error obteniendo el match, inténtalo de nuevo más tarde")
+}
+
+// commandAuditEntry prepara la entrada de auditoría de un comando, con la misma acción
+// que su endpoint REST para poder filtrarlos juntos
+func commandAuditEntry(user *models.User, action string, status int, targetType string, targetID, tournamentID int) *models.AuditEntry {
+	return &models.AuditEntry{
+		ActorUserID:  &user.ID,
+		Action:       action,
+		Method:       http.MethodPost,
+		Path:         "/discord/interactions",
+		TargetType:   targetType,
+		TargetID:     &targetID,
+		TournamentID: &tournamentID,
+		StatusCode:   status,
+	}
+}
+
+func auditCommand(user *models.User, action string, status int, targetType string, targetID, tournamentID int) {
+	audit.Record(commandAuditEntry(user, action, status, targetType, targetID, tournamentID))
+}
+
+func commandNext(in interaction, user *models.User) *interactionResponseData {
+	tournamentID, _ := in.intOption("tournament")
+
+	m, err := database.GetNextMatchForPlayer(tournamentID, user.ID)
+	if err != nil {
+		return reply("Error obteniendo tu próximo match")
+	}
+	if m == nil {
+		return reply("No tienes matches pendientes")
+	}
+
+	opponent := "por determinar"
+	if m.Player1 != nil && m.Player1.ID != user.ID {
+		opponent = m.Player1.Username
+	} else if m.Player2 != nil && m.Player2.ID != user.ID {
+		opponent = m.Player2.Username
+	}
+
+	return reply(fmt.Sprintf("Tu próximo match es el #%d (torneo #%d, ronda %d) contra **%s**",
+		m.ID, m.TournamentID, m.Round, opponent))
+}
+
+func commandBracket(in interaction) *interactionResponseData {
+	tournamentID, ok := in.intOption("tournament")
+	if !ok {
+		return reply("Indica el ID del torneo")
+	}
+
+	t, err := database.GetTournamentByID(tournamentID)
+	if err != nil {
+		return reply("Torneo no encontrado")
+	}
+	matches, err := database.GetMatchDetailsByTournamentID(tournamentID)
+	if err != nil {
+		return reply("Error obteniendo el bracket")
+	}
+	if len(matches) == 0 {
+		return reply("El bracket de este torneo aún no se ha generado")
+	}
+
+	rounds := map[int][]string{}
+	for _, m := range matches {
+		rounds[m.Round] = append(rounds[m.Round], bracketLine(m))
+	}
+	var order []int
+	for r := range rounds {
+		order = append(order, r)
+	}
+	sort.Ints(order)
+
+	embed := Embed{Title: "Bracket: " + t.Name, URL: tournamentLink(t.ID), Color: ColorInfo}
+	for _, r := range order {
+		embed.Fields = append(embed.Fields, EmbedField{
+			Name:  "Ronda " + strconv.Itoa(r),
+			Value: truncateField(strings.Join(rounds[r], "\n")),
+		})
+	}
+
+	return &interactionResponseData{Embeds: []Embed{embed}}
+}
+
+func bracketLine(m models.Match) string {
+	name := func(u *models.User) string {
+		if u == nil {
+			return "—"
+		}
+		if m.Winner != nil && m.Winner.ID == u.ID {
+			return "**" + u.Username + "**"
+		}
+		return u.Username
+	}
+	return fmt.Sprintf("#%d %s vs %s", m.ID, name(m.Player1), name(m.Player2))
+}
+
+// Límite de Discord para el valor de un campo de embed
+const maxFieldValue = 1024
+
+func truncateField(s string) string {
+	if r := []rune(s); len(r) > maxFieldValue {
+		return string(r[:maxFieldValue-1]) + "…"
+	}
+	return s
+}
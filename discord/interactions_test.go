@@ -0,0 +1,78 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":1}`)
+	sign := func(timestamp string, body []byte) string {
+		return hex.EncodeToString(ed25519.Sign(private, append([]byte(timestamp), body...)))
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	valid := sign(timestamp, body)
+
+	stale := strconv.FormatInt(now.Add(-signatureMaxAge-time.Second).Unix(), 10)
+	future := strconv.FormatInt(now.Add(signatureMaxAge+time.Second).Unix(), 10)
+	recent := strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		signature string
+		timestamp string
+		body      []byte
+		want      bool
+	}{
+		{name: "firma válida", signature: valid, timestamp: timestamp, body: body, want: true},
+		{name: "firma en mayúsculas", signature: strings.ToUpper(valid), timestamp: timestamp, body: body, want: true},
+		{name: "timestamp reciente", signature: sign(recent, body), timestamp: recent, body: body, want: true},
+		{name: "cuerpo alterado", signature: valid, timestamp: timestamp, body: []byte(`{"type":2}`)},
+		{name: "timestamp alterado", signature: valid, timestamp: recent, body: body},
+		{name: "hex inválido", signature: "zz" + valid[2:], timestamp: timestamp, body: body},
+		{name: "firma corta", signature: valid[:len(valid)-2], timestamp: timestamp, body: body},
+		{name: "firma vacía", signature: "", timestamp: timestamp, body: body},
+		{name: "timestamp vacío", signature: sign("", body), timestamp: "", body: body},
+		{name: "timestamp no numérico", signature: sign("ayer", body), timestamp: "ayer", body: body},
+		{name: "timestamp caducado", signature: sign(stale, body), timestamp: stale, body: body},
+		{name: "timestamp futuro", signature: sign(future, body), timestamp: future, body: body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifySignature(public, tt.signature, tt.timestamp, tt.body, now); got != tt.want {
+				t.Fatalf("verifySignature = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureOtherKey(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"type":1}`)
+	signature := hex.EncodeToString(ed25519.Sign(private, append([]byte(timestamp), body...)))
+
+	if verifySignature(other, signature, timestamp, body, now) {
+		t.Fatal("se aceptó una firma hecha con otra clave")
+	}
+}
@@ -0,0 +1,233 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Colores de los embeds
+const (
+	ColorInfo    = 0x5865F2
+	ColorSuccess = 0x57F287
+	ColorGold    = 0xF1C40F
+)
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type EmbedFooter struct {
+	Text string `json:"text"`
+}
+
+type EmbedImage struct {
+	URL string `json:"url"`
+}
+
+type Embed struct {
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Color       int          `json:"color,omitempty"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+	Thumbnail   *EmbedImage  `json:"thumbnail,omitempty"`
+	Image       *EmbedImage  `json:"image,omitempty"`
+	Footer      *EmbedFooter `json:"footer,omitempty"`
+	Timestamp   string       `json:"timestamp,omitempty"`
+}
+
+// WebhookMessage es el cuerpo que se envía a un webhook de Discord
+type WebhookMessage struct {
+	Username string  `json:"username,omitempty"`
+	Content  string  `json:"content,omitempty"`
+	Embeds   []Embed `json:"embeds,omitempty"`
+}
+
+const (
+	// Límite de Discord para la descripción de un embed
+	maxDescription = 4096
+
+	// Reintentos de un mensaje tras un 429 o un error del servidor
+	maxSendAttempts = 3
+
+	// Una cola sin mensajes durante este tiempo cierra su goroutine
+	queueIdle = time.Minute
+
+	queueSize = 64
+)
+
+var (
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+
+	queuesMu sync.Mutex
+	queues   = map[string]chan WebhookMessage{}
+)
+
+// Hosts aceptados como webhook de Discord. DISCORD_WEBHOOK_HOSTS añade otros separados
+// por comas, p. ej. un servidor local que simule Discord en desarrollo.
+var defaultWebhookHosts = []string{"discord.com", "discordapp.com", "ptb.discord.com", "canary.discord.com"}
+
+// ValidateWebhookURL comprueba que la URL sea un webhook de Discord
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("URL de webhook inválida")
+	}
+
+	for _, h := range strings.Split(os.Getenv("DISCORD_WEBHOOK_HOSTS"), ",") {
+		if h = strings.TrimSpace(h); h != "" && u.Host == h {
+			return nil
+		}
+	}
+
+	if u.Scheme != "https" || !strings.HasPrefix(u.Path, "/api/webhooks/") {
+		return errors.New("la URL debe ser un webhook de Discord (https://discord.com/api/webhooks/...)")
+	}
+	for _, h := range defaultWebhookHosts {
+		if u.Host == h {
+			return nil
+		}
+	}
+	return errors.New("la URL debe ser un webhook de Discord (https://discord.com/api/webhooks/...)")
+}
+
+// Post encola el mensaje para el webhook sin bloquear. Cada webhook tiene su propia
+// cola y goroutine, así los límites de uno no retrasan a los demás y los mensajes
+// de un mismo torneo llegan en orden.
+func Post(webhookURL string, msg WebhookMessage) {
+	queuesMu.Lock()
+	defer queuesMu.Unlock()
+
+	q, ok := queues[webhookURL]
+	if !ok {
+		q = make(chan WebhookMessage, queueSize)
+		queues[webhookURL] = q
+		go runQueue(webhookURL, q)
+	}
+
+	select {
+	case q <- msg:
+	default:
+		log.Printf("Cola de Discord llena, mensaje descartado")
+	}
+}
+
+func runQueue(webhookURL string, q chan WebhookMessage) {
+	// Momento hasta el que Discord nos ha pedido no enviar (cabeceras X-RateLimit-*)
+	var blockedUntil time.Time
+
+	for {
+		select {
+		case msg := <-q:
+			blockedUntil = sendWithRetry(webhookURL, msg, blockedUntil)
+		case <-time.After(queueIdle):
+			queuesMu.Lock()
+			if len(q) > 0 {
+				queuesMu.Unlock()
+				continue
+			}
+			delete(queues, webhookURL)
+			queuesMu.Unlock()
+			return
+		}
+	}
+}
+
+// sendWithRetry envía el mensaje respetando los límites de Discord y devuelve hasta
+// cuándo hay que esperar antes del siguiente envío
+func sendWithRetry(webhookURL string, msg WebhookMessage, blockedUntil time.Time) time.Time {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error serializando el mensaje de Discord: %v", err)
+		return blockedUntil
+	}
+
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		if wait := time.Until(blockedUntil); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		res, err := httpClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Error enviando mensaje al webhook de Discord %s: %v", redactWebhook(webhookURL), unwrapURLError(err))
+			blockedUntil = time.Now().Add(time.Duration(attempt) * time.Second)
+			continue
+		}
+		resBody, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+		res.Body.Close()
+
+		blockedUntil = rateLimitReset(res.Header)
+
+		switch {
+		case res.StatusCode == http.StatusTooManyRequests:
+			blockedUntil = time.Now().Add(retryAfter(res.Header, resBody))
+		case res.StatusCode >= 500:
+			blockedUntil = time.Now().Add(time.Duration(attempt) * time.Second)
+		case res.StatusCode >= 300:
+			// 4xx: webhook borrado o mensaje inválido, reintentar no sirve
+			log.Printf("Discord rechazó el mensaje (%d): %s", res.StatusCode, resBody)
+			return blockedUntil
+		default:
+			return blockedUntil
+		}
+	}
+
+	log.Printf("Mensaje de Discord descartado tras %d intentos", maxSendAttempts)
+	return blockedUntil
+}
+
+// redactWebhook deja solo el host del webhook: el resto de la URL incluye su token
+func redactWebhook(webhookURL string) string {
+	if u, err := url.Parse(webhookURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "(URL inválida)"
+}
+
+// unwrapURLError quita la URL que net/http añade a los errores de la petición
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// rateLimitReset lee X-RateLimit-Remaining y X-RateLimit-Reset-After: si no quedan
+// peticiones en el bucket hay que esperar al reset
+func rateLimitReset(h http.Header) time.Time {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}
+	}
+	seconds, err := strconv.ParseFloat(h.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(seconds * float64(time.Second)))
+}
+
+// retryAfter devuelve la espera pedida en un 429 (cabecera Retry-After o campo retry_after)
+func retryAfter(h http.Header, body []byte) time.Duration {
+	var payload struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.RetryAfter > 0 {
+		return time.Duration(payload.RetryAfter * float64(time.Second))
+	}
+	if seconds, err := strconv.ParseFloat(h.Get("Retry-After"), 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return time.Second
+}
@@ -1,27 +1,35 @@
 package main
 
 import (
-	"bytes"
+	"archive/zip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"syscall"
 	"time"
 
+	"torneos/audit"
 	"torneos/auth"
 	"torneos/database"
+	"torneos/discord"
 	"torneos/models"
 	"torneos/realtime"
 	"torneos/utils"
+	"torneos/webhooks"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/joho/godotenv"
 )
 
@@ -40,6 +48,10 @@ func main() {
 	if err := database.RunMigrations(); err != nil {
 		log.Fatalf("Error aplicando migración: %v", err)
 	}
+
+	if err := database.BackfillPlacements(); err != nil {
+		log.Printf("Error calculando clasificaciones pendientes: %v", err)
+	}
 	// Redireccionar al frontend con el token
 	frontendURL := os.Getenv("FRONTEND_URL")
 	if frontendURL == "" {
@@ -48,8 +60,33 @@ func main() {
 	log.Println("CORS: Allowing origin ->", frontendURL)
 	router := gin.Default()
 
+	realtime.Configure(auth.AuthenticateToken, frontendURL, "http://localhost:3000")
+
+	// Con varias instancias detrás de un balanceador los eventos se reparten por Postgres
+	if os.Getenv("REALTIME_BROKER") == "postgres" {
+		if err := realtime.UseBroker(realtime.NewPostgresBroker(database.DB)); err != nil {
+			log.Fatalf("Error iniciando el broker de eventos: %v", err)
+		}
+	}
+
+	auth.LoadProviders()
+	auth.StartSessionCleanup()
+	webhooks.Start()
+	discord.Start(frontendURL)
+
 	router.GET("/ws", realtime.WebSocketHandler)
 
+	// Comandos de barra de Discord; la URL se configura en el portal de desarrolladores.
+	// Va antes del middleware de auditoría: cada comando que modifica datos guarda su
+	// propia entrada con el usuario vinculado (ver discord.InteractionsHandler).
+	if key := os.Getenv("DISCORD_PUBLIC_KEY"); key != "" {
+		handler, err := discord.InteractionsHandler(key)
+		if err != nil {
+			log.Fatalf("Error configurando las interacciones de Discord: %v", err)
+		}
+		router.POST("/discord/interactions", handler)
+	}
+
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{frontendURL, "http://localhost:3000"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
@@ -58,128 +95,284 @@ func main() {
 		AllowCredentials: true,
 	}))
 
+	// Registro de auditoría de todas las rutas que modifican datos (ver audit.Middleware)
+	router.Use(audit.Middleware())
+
 	router.Static("/uploads", "./uploads")
 
 	router.GET("/", func(c *gin.Context) {
 		c.String(200, "¡Servidor con Gin funcionando!")
 	})
 
-	router.GET("/api/users", func(c *gin.Context) {
-		users, err := database.GetAllUsers()
+	router.GET("/api/users", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		limit, offset, err := parsePagination(c, 50)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		users, total, err := database.GetUsers(limit, offset)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error al obtener los usuarios"})
 			return
 		}
+
+		c.Header("X-Total-Count", strconv.Itoa(total))
 		c.JSON(200, users)
 	})
 
-	router.GET("/auth/discord/login", func(c *gin.Context) {
-		clientID := os.Getenv("DISCORD_CLIENT_ID")
-		redirectURI := os.Getenv("DISCORD_REDIRECT_URI")
+	redirectOrigins := auth.RedirectOrigins(frontendURL, "http://localhost:3000")
+
+	// Los errores del inicio de sesión vuelven al frontend, que es quien los muestra
+	loginError := func(c *gin.Context, code string) {
+		c.Redirect(http.StatusTemporaryRedirect, frontendURL+"/auth/callback?error="+url.QueryEscape(code))
+	}
+
+	// Con ?redirect_to= se vuelve a esa página tras iniciar sesión. La vinculación de
+	// proveedores empieza en /api/auth/link/:provider, no aquí.
+	router.GET("/auth/:provider/login", func(c *gin.Context) {
+		provider, err := auth.GetProvider(c.Param("provider"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		redirectTo := c.Query("redirect_to")
+		if _, _, err := auth.ValidateRedirect(redirectTo, redirectOrigins); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		state, err := auth.NewLoginState(provider.Name())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "No se pudo iniciar el inicio de sesión"})
+			return
+		}
+		state.RedirectTo = redirectTo
 
-		url := "https://discord.com/oauth2/authorize" +
-			"?client_id=" + clientID +
-			"&redirect_uri=" + redirectURI +
-			"&response_type=code" +
-			"&scope=identify"
+		if err := state.SetCookie(c); err != nil {
+			c.JSON(500, gin.H{"error": "No se pudo iniciar el inicio de sesión"})
+			return
+		}
 
-		c.Redirect(302, url)
+		c.Redirect(http.StatusFound, provider.AuthURL(state.State, state.CodeChallenge()))
 	})
 
-	router.GET("/auth/discord/callback", func(c *gin.Context) {
-		code := c.Query("code")
-		if code == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No se proporcionó el código de autorización"})
+	router.GET("/auth/:provider/callback", func(c *gin.Context) {
+		provider, err := auth.GetProvider(c.Param("provider"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
 
-		data := url.Values{}
-		data.Set("client_id", os.Getenv("DISCORD_CLIENT_ID"))
-		data.Set("client_secret", os.Getenv("DISCORD_CLIENT_SECRET"))
-		data.Set("grant_type", "authorization_code")
-		data.Set("code", code)
-		data.Set("redirect_uri", os.Getenv("DISCORD_REDIRECT_URI"))
-		data.Set("scope", "identify")
+		state, err := auth.ConsumeLoginState(c, provider.Name())
+		if err != nil {
+			loginError(c, "invalid_state")
+			return
+		}
 
-		req, err := http.NewRequest("POST", "https://discord.com/api/oauth2/token", bytes.NewBufferString(data.Encode()))
+		identity, err := provider.Callback(c.Request.Context(), c.Request.URL.Query(), state.CodeVerifier)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creando la solicitud de token"})
+			log.Printf("Error en el callback de %s: %v", provider.Name(), err)
+			loginError(c, "provider_error")
+			return
+		}
+
+		// Vinculación de un proveedor más a una cuenta existente
+		if state.LinkUserID != 0 {
+			if err := database.LinkIdentity(state.LinkUserID, *identity); err != nil {
+				if errors.Is(err, database.ErrIdentityTaken) {
+					c.Redirect(http.StatusTemporaryRedirect, frontendURL+"/profile?link_error=taken")
+					return
+				}
+				c.JSON(500, gin.H{"error": "Error al vincular la cuenta"})
+				return
+			}
+			c.Redirect(http.StatusTemporaryRedirect, frontendURL+"/profile?linked="+identity.Provider)
 			return
 		}
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-		client := http.Client{Timeout: 10 * time.Second}
-		res, err := client.Do(req)
+		user, err := database.FindUserByIdentity(identity.Provider, identity.ProviderUserID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error enviando la solicitud de token"})
+			user, err = database.CreateUserWithIdentity(*identity)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "Error al crear el usuario"})
+				return
+			}
+		}
+
+		if err := database.CheckUserActive(user.ID, time.Now()); err != nil {
+			loginError(c, "account_disabled")
 			return
 		}
-		defer res.Body.Close()
 
-		body, _ := io.ReadAll(res.Body)
+		// La sesión queda en la cookie httpOnly; el frontend obtiene el token de acceso
+		// con POST /auth/refresh, así el token no viaja en la URL
+		if _, err := auth.IssueSession(c, user.ID); err != nil {
+			c.JSON(500, gin.H{"error": "No se pudo iniciar la sesión"})
+			return
+		}
+
+		// El redirect_to se validó al guardarlo, pero la lista de orígenes puede haber cambiado
+		origin, path, err := auth.ValidateRedirect(state.RedirectTo, redirectOrigins)
+		if err != nil {
+			origin, path = frontendURL, ""
+		}
+
+		redirectURL := origin + "/auth/callback"
+		if path != "" {
+			redirectURL += "?redirect_to=" + url.QueryEscape(path)
+		}
+		c.Redirect(http.StatusTemporaryRedirect, redirectURL)
+	})
 
-		var tokenResp struct {
-			AccessToken string `json:"access_token"`
-			TokenType   string `json:"token_type"`
+	router.POST("/auth/refresh", func(c *gin.Context) {
+		token, err := auth.RefreshSession(c)
+		switch {
+		case errors.Is(err, database.ErrUserBanned):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Usuario suspendido"})
+			return
+		case errors.Is(err, database.ErrRefreshTokenInvalid), errors.Is(err, database.ErrRefreshTokenReused),
+			errors.Is(err, database.ErrUserNotFound), errors.Is(err, database.ErrSessionRevoked):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesión no válida"})
+			return
+		case err != nil:
+			c.JSON(500, gin.H{"error": "No se pudo renovar la sesión"})
+			return
 		}
-		if err := json.Unmarshal(body, &tokenResp); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decodificando la respuesta de token"})
+
+		c.JSON(http.StatusOK, gin.H{"token": token, "expires_in": int(auth.AccessTokenTTL.Seconds())})
+	})
+
+	router.POST("/auth/logout", func(c *gin.Context) {
+		if err := auth.EndSession(c); err != nil {
+			c.JSON(500, gin.H{"error": "Error al cerrar la sesión"})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "Sesión cerrada"})
+	})
 
-		req, _ = http.NewRequest("GET", "https://discord.com/api/users/@me", nil)
-		req.Header.Set("Authorization", tokenResp.TokenType+" "+tokenResp.AccessToken)
+	// Cierra la sesión en todos los dispositivos, incluidos los tokens de acceso vigentes
+	router.POST("/auth/logout-all", auth.AuthMiddleware(), func(c *gin.Context) {
+		if err := database.RevokeUserSessions(c.GetInt("user_id")); err != nil {
+			c.JSON(500, gin.H{"error": "Error al cerrar las sesiones"})
+			return
+		}
+		realtime.DisconnectUser(c.GetInt("user_id"))
+		auth.EndSession(c)
+		c.JSON(http.StatusOK, gin.H{"message": "Todas las sesiones cerradas"})
+	})
 
-		res, err = client.Do(req)
+	// Inicia la vinculación de otro proveedor: guarda en la cookie de estado el usuario de
+	// la sesión y devuelve la URL del proveedor a la que el frontend debe redirigir. La
+	// petición debe hacerse con credenciales (y, si el frontend está en otro sitio, con
+	// COOKIE_SAMESITE=none) para que el navegador guarde la cookie; así el callback solo
+	// vincula en el navegador que inició la vinculación.
+	router.POST("/api/auth/link/:provider", auth.AuthMiddleware(), func(c *gin.Context) {
+		provider, err := auth.GetProvider(c.Param("provider"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error obteniendo el perfil del usuario"})
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
-		defer res.Body.Close()
 
-		body, _ = io.ReadAll(res.Body)
+		state, err := auth.NewLoginState(provider.Name())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "No se pudo iniciar la vinculación"})
+			return
+		}
+		state.LinkUserID = c.GetInt("user_id")
 
-		var userData struct {
-			ID       string `json:"id"`
-			Username string `json:"username"`
-			Avatar   string `json:"avatar"`
+		if err := state.SetCookie(c); err != nil {
+			c.JSON(500, gin.H{"error": "No se pudo iniciar la vinculación"})
+			return
 		}
-		if err := json.Unmarshal(body, &userData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decodificando perfil del usuario"})
+
+		c.JSON(http.StatusOK, gin.H{"url": provider.AuthURL(state.State, state.CodeChallenge())})
+	})
+
+	router.GET("/api/profile/identities", auth.AuthMiddleware(models.ScopeRead), func(c *gin.Context) {
+		identities, err := database.GetUserIdentities(c.GetInt("user_id"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener las cuentas vinculadas"})
 			return
 		}
+		c.JSON(http.StatusOK, identities)
+	})
 
-		avatarURL := "https://cdn.discordapp.com/avatars/" + userData.ID + "/" + userData.Avatar + ".png"
+	router.DELETE("/api/profile/identities/:provider", auth.AuthMiddleware(), func(c *gin.Context) {
+		if err := database.UnlinkIdentity(c.GetInt("user_id"), c.Param("provider")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Cuenta desvinculada"})
+	})
 
-		user, err := database.FindUserByOAuth("discord", userData.ID)
+	// Tokens de API para bots y herramientas externas. Solo se gestionan con una sesión.
+	router.GET("/api/tokens", auth.AuthMiddleware(), func(c *gin.Context) {
+		tokens, err := database.GetUserAPITokens(c.GetInt("user_id"))
 		if err != nil {
-			user = &models.User{
-				Username:      userData.Username,
-				Email:         "",
-				OAuthProvider: "discord",
-				OAuthID:       userData.ID,
-				AvatarURL:     avatarURL,
-			}
-			user, err = database.CreateUser(user)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "Error al crear el usuario"})
-				return
-			}
+			c.JSON(500, gin.H{"error": "Error al obtener los tokens"})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
+	})
+
+	router.POST("/api/tokens", auth.AuthMiddleware(), func(c *gin.Context) {
+		var input struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expires_in_days"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil || input.Name == "" || len(input.Name) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nombre inválido"})
+			return
+		}
+		if !auth.ValidScopes(input.Scopes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Permisos inválidos", "valid_scopes": models.APITokenScopes})
+			return
+		}
+		if input.ExpiresInDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Caducidad inválida"})
+			return
 		}
 
-		// Generar JWT
-		token, err := auth.GenerateJWT(user.ID)
+		token, hash, prefix, err := auth.NewAPIToken()
 		if err != nil {
-			c.JSON(500, gin.H{"error": "No se pudo generar el token"})
+			c.JSON(500, gin.H{"error": "Error al generar el token"})
 			return
 		}
 
-		redirectURL := fmt.Sprintf("%s/auth/callback?token=%s", frontendURL, token)
-		c.Redirect(http.StatusTemporaryRedirect, redirectURL)
+		t := models.APIToken{
+			UserID: c.GetInt("user_id"),
+			Name:   input.Name,
+			Prefix: prefix,
+			Scopes: input.Scopes,
+		}
+		if err := database.CreateAPIToken(&t, hash, time.Duration(input.ExpiresInDays)*24*time.Hour); err != nil {
+			c.JSON(500, gin.H{"error": "Error al crear el token"})
+			return
+		}
+
+		// El token solo se muestra ahora; después solo queda su prefijo
+		t.Token = token
+		c.JSON(http.StatusCreated, t)
+	})
+
+	router.DELETE("/api/tokens/:token_id", auth.AuthMiddleware(), func(c *gin.Context) {
+		tokenID, err := strconv.Atoi(c.Param("token_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de token inválido"})
+			return
+		}
 
+		if err := database.RevokeAPIToken(c.GetInt("user_id"), tokenID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Token revocado"})
 	})
 
-	router.GET("/api/profile", auth.AuthMiddleware(), func(c *gin.Context) {
+	router.GET("/api/profile", auth.AuthMiddleware(models.ScopeRead), func(c *gin.Context) {
 		userID := c.GetInt("user_id")
 
 		user, err := database.GetUserByID(userID)
@@ -188,17 +381,107 @@ func main() {
 			return
 		}
 
-		c.JSON(200, gin.H{
-			"id":        user.ID,
-			"username":  user.Username,
-			"avatar":    user.AvatarURL,
-			"createdAt": user.CreatedAt,
-			"twitch":    user.Twitch,
-			"youtube":   user.YouTube,
-		})
+		role, err := database.GetUserRole(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "No se pudo obtener el perfil"})
+			return
+		}
+
+		c.JSON(200, models.NewPrivateUser(user, role))
+	})
+
+	router.PUT("/api/profile/privacy", auth.AuthMiddleware(), func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+
+		var input models.UserVisibility
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": "JSON inválido"})
+			return
+		}
+		for _, v := range []string{input.MatchHistory, input.Socials, input.Stats} {
+			if v != "" && !models.ValidVisibility(v) {
+				c.JSON(400, gin.H{"error": "Visibilidad inválida: usa public, registered o private"})
+				return
+			}
+		}
+
+		before, err := database.GetUserByID(userID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+
+		visibility, err := database.SetUserVisibility(userID, input)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al actualizar la privacidad"})
+			return
+		}
+
+		audit.SetAction(c, "profile.privacy")
+		audit.SetTarget(c, "user", userID)
+		audit.SetBefore(c, before.Visibility)
+		audit.SetAfter(c, visibility)
+
+		c.JSON(200, visibility)
+	})
+
+	// Exporta todos los datos del usuario: JSON por defecto o, con ?format=zip, un ZIP con
+	// el mismo JSON y las capturas que ha subido
+	router.GET("/api/profile/export", auth.AuthMiddleware(), func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "zip" {
+			c.JSON(400, gin.H{"error": "Formato inválido: usa json o zip"})
+			return
+		}
+
+		export, err := database.ExportUserData(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al exportar los datos"})
+			return
+		}
+
+		filename := fmt.Sprintf("torneos-usuario-%d", userID)
+		if format == "json" {
+			c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+			c.JSON(200, export)
+			return
+		}
+
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		c.Status(200)
+		if err := writeExportZip(c.Writer, export); err != nil {
+			log.Printf("Error al generar la exportación del usuario %d: %v", userID, err)
+		}
+	})
+
+	// Borra la cuenta. El usuario se anonimiza en lugar de eliminarse para no romper los
+	// brackets ni el historial de los demás jugadores.
+	router.DELETE("/api/profile", auth.AuthMiddleware(), func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+
+		err := database.AnonymizeUser(userID)
+		if errors.Is(err, database.ErrOwnsActiveTournaments) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al borrar la cuenta"})
+			return
+		}
+		realtime.DisconnectUser(userID)
+		auth.EndSession(c)
+
+		audit.SetAction(c, "profile.delete")
+		audit.SetTarget(c, "user", userID)
+		audit.OmitIP(c)
+
+		c.JSON(200, gin.H{"message": "Cuenta borrada"})
 	})
 
-	router.POST("/api/tournaments", auth.AuthMiddleware(), func(c *gin.Context) {
+	router.POST("/api/tournaments", auth.AuthMiddleware(models.ScopeManageTournaments), func(c *gin.Context) {
 		var input models.CreateTournamentRequest
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -230,10 +513,12 @@ func main() {
 			return
 		}
 
-		_, err = database.DB.Exec(context.Background(), `
+		var tournamentID int
+		err = database.DB.QueryRow(context.Background(), `
         INSERT INTO tournaments (
             name, game, type, description, rules, platform, start_time, max_participants, banner_url, format, created_by_user_id, created_at
         ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        RETURNING id
     `,
 			input.Name,
 			input.Game,
@@ -247,7 +532,7 @@ func main() {
 			input.Format,
 			userID,
 			time.Now(),
-		)
+		).Scan(&tournamentID)
 
 		if err != nil {
 			fmt.Println("INSERT ERROR:", err)
@@ -255,7 +540,12 @@ func main() {
 			return
 		}
 
-		c.JSON(201, gin.H{"message": "Torneo creado correctamente"})
+		audit.SetAction(c, "tournament.create")
+		audit.SetTarget(c, "tournament", tournamentID)
+		audit.SetTournament(c, tournamentID)
+		audit.SetAfter(c, input)
+
+		c.JSON(201, gin.H{"message": "Torneo creado correctamente", "id": tournamentID})
 	})
 
 	router.GET("/api/tournaments", func(c *gin.Context) {
@@ -277,7 +567,13 @@ func main() {
 			return
 		}
 
+		audit.SetAction(c, "tournament.join")
+
 		participant, err := database.JoinTournament(userID, tournamentID)
+		if errors.Is(err, database.ErrBannedFromTournament) {
+			c.JSON(403, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
@@ -286,6 +582,23 @@ func main() {
 		c.JSON(201, participant)
 	})
 
+	router.POST("/api/tournaments/:id/checkin", auth.AuthMiddleware(), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de torneo inválido"})
+			return
+		}
+
+		if err := database.CheckInParticipant(c.GetInt("user_id"), tournamentID); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		audit.SetAction(c, "tournament.checkin")
+
+		c.JSON(200, gin.H{"message": "Check-in realizado correctamente"})
+	})
+
 	router.GET("/api/tournaments/:id", func(c *gin.Context) {
 		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
@@ -306,9 +619,16 @@ func main() {
 			return
 		}
 
+		placements, err := database.GetTournamentPlacements(tournamentID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener la clasificación"})
+			return
+		}
+
 		c.JSON(200, gin.H{
 			"tournament":   tournament,
 			"participants": participants,
+			"placements":   placements,
 		})
 	})
 
@@ -346,26 +666,13 @@ func main() {
 		})
 	})
 
-	router.POST("/api/tournaments/:id/bracket/generate", auth.AuthMiddleware(), func(c *gin.Context) {
+	router.POST("/api/tournaments/:id/bracket/generate", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.GenerateBracket), func(c *gin.Context) {
 		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
 			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		userID := c.GetInt("user_id")
-
-		tournament, err := database.GetTournamentByID(tournamentID)
-		if err != nil {
-			c.JSON(404, gin.H{"error": "Torneo no encontrado"})
-			return
-		}
-
-		if tournament.CreatedByUserID != userID {
-			c.JSON(403, gin.H{"error": "Solo el creador del torneo puede generar el bracket"})
-			return
-		}
-
 		participants, err := database.GetParticipantsByTournamentID(tournamentID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error al obtener participantes"})
@@ -380,9 +687,9 @@ func main() {
 		for _, u := range participants {
 			_, err := database.DB.Exec(context.Background(), `
             UPDATE users
-            SET points = points + 5
+            SET points = points + $2
             WHERE id = $1
-        `, u.ID)
+        `, u.ID, database.ParticipationPoints)
 
 			if err != nil {
 				c.JSON(500, gin.H{"error": fmt.Sprintf("Error actualizando puntos de participación para el usuario %d", u.ID)})
@@ -413,14 +720,26 @@ func main() {
 				m.Player2ID = &id2
 			}
 
-			_, err := database.InsertMatch(m)
+			inserted, err := database.InsertMatch(m)
 			if err != nil {
 				c.JSON(500, gin.H{"error": "Error guardando match"})
 				return
 			}
+
+			database.NotifyMatchReady(inserted.ID)
+		}
+
+		matches, err := database.GetMatchDetailsByTournamentID(tournamentID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener los matches generados"})
+			return
 		}
 
-		realtime.Broadcast(fmt.Sprintf("EVENT:BRACKET|TOURNAMENT:%d|MESSAGE:Bracket generado", tournamentID))
+		realtime.Broadcast(realtime.NewBracketGeneratedEvent(tournamentID, matches))
+		database.NotifyBracketGenerated(tournamentID)
+
+		audit.SetAction(c, "bracket.generate")
+		audit.SetAfter(c, gin.H{"participants": len(participants), "matches": len(matches)})
 
 		c.JSON(201, gin.H{"message": "Bracket generado y guardado correctamente"})
 	})
@@ -441,80 +760,190 @@ func main() {
 		c.JSON(200, matches)
 	})
 
-	router.POST("/api/matches/:id/report", auth.AuthMiddleware(), func(c *gin.Context) {
-		userID := c.GetInt("user_id")
-		matchID, err := strconv.Atoi(c.Param("id"))
+	router.GET("/api/tournaments/:id/events", func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
 			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		var input struct {
-			WinnerID int `json:"winner_id"`
-		}
-
-		if err := c.ShouldBindJSON(&input); err != nil || input.WinnerID == 0 {
-			c.JSON(400, gin.H{"error": "Debe especificar el ID del ganador"})
-			return
+		var since uint64
+		if v := c.Query("since"); v != "" {
+			since, err = strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Parámetro since inválido"})
+				return
+			}
 		}
 
-		// Necesitamos el torneo_id del match para incluirlo en la notificación
-		var tournamentID int
-		err = database.DB.QueryRow(context.Background(), `
-        SELECT tournament_id FROM matches WHERE id = $1
-    `, matchID).Scan(&tournamentID)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Error obteniendo el torneo del match"})
-			return
-		}
+		c.JSON(200, realtime.EventsSince(realtime.TournamentTopic(tournamentID), since))
+	})
 
-		// Reportar el resultado
-		err = database.ReportMatchResult(matchID, userID, input.WinnerID)
+	router.GET("/api/tournaments/:id/stream", func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(403, gin.H{"error": err.Error()})
+			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		realtime.Broadcast(fmt.Sprintf(
-			"EVENT:MATCH_RESULT|MATCH:%d|TOURNAMENT:%d|MESSAGE:Resultado reportado",
-			matchID, tournamentID,
-		))
-
-		c.JSON(200, gin.H{"message": "Resultado reportado correctamente"})
+		realtime.ServeSSE(c, realtime.TournamentTopic(tournamentID))
 	})
 
-	router.GET("/api/users/:id", func(c *gin.Context) {
-		idParam := c.Param("id")
-		id, err := strconv.Atoi(idParam)
+	router.GET("/api/tournaments/:id/presence", func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		user, err := database.GetUserByID(id)
+		participants, err := database.GetParticipantsByTournamentID(tournamentID)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Usuario no encontrado"})
+			c.JSON(500, gin.H{"error": "Error al obtener participantes"})
 			return
 		}
 
-		c.JSON(http.StatusOK, user)
-	})
+		presence := realtime.TournamentPresence(tournamentID)
+		watching := map[int]bool{}
+		for _, id := range presence.UserIDs {
+			watching[id] = true
+		}
 
-	router.GET("/api/auth/me", auth.AuthMiddleware(), func(c *gin.Context) {
-		c.Request.URL.Path = "/api/profile"
-		router.HandleContext(c)
+		// online: conectado en cualquier página; watching: con el torneo abierto
+		players := []gin.H{}
+		onlineCount := 0
+		for _, u := range participants {
+			online := realtime.UserOnline(u.ID) || watching[u.ID]
+			if online {
+				onlineCount++
+			}
+			players = append(players, gin.H{
+				"id":         u.ID,
+				"username":   u.Username,
+				"avatar_url": u.AvatarURL,
+				"online":     online,
+				"watching":   watching[u.ID],
+			})
+		}
+
+		c.JSON(200, gin.H{
+			"tournament_id":       tournamentID,
+			"watchers":            presence.Watchers,
+			"online_participants": onlineCount,
+			"participants":        players,
+		})
 	})
 
-	router.DELETE("/api/tournaments/:id/leave", auth.AuthMiddleware(), func(c *gin.Context) {
-		tournamentID, err := strconv.Atoi(c.Param("id"))
+	router.POST("/api/matches/:id/report", auth.AuthMiddleware(models.ScopeReportResults), func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+		matchID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
 			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		userID := c.GetInt("user_id")
-
-		err = database.LeaveTournament(tournamentID, userID)
+		var input struct {
+			WinnerID int `json:"winner_id"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil || input.WinnerID == 0 {
+			c.JSON(400, gin.H{"error": "Debe especificar el ID del ganador"})
+			return
+		}
+
+		allowed, err := auth.CanReportMatch(userID, matchID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "Match no encontrado"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al comprobar los permisos"})
+			return
+		}
+		if !allowed {
+			c.JSON(403, gin.H{"error": "no tienes permiso para reportar este match"})
+			return
+		}
+
+		// Necesitamos el torneo_id del match para incluirlo en la notificación
+		var tournamentID int
+		err = database.DB.QueryRow(context.Background(), `
+        SELECT tournament_id FROM matches WHERE id = $1
+    `, matchID).Scan(&tournamentID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error obteniendo el torneo del match"})
+			return
+		}
+
+		before, err := database.GetMatchByID(matchID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "Match no encontrado"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error obteniendo el match"})
+			return
+		}
+
+		// Reportar el resultado
+		err = database.ReportMatchResult(matchID, userID, input.WinnerID)
+		if errors.Is(err, database.ErrMatchAlreadyReported) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(403, gin.H{"error": err.Error()})
+			return
+		}
+
+		after, err := database.PublishMatchResult(matchID, userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error obteniendo el match actualizado"})
+			return
+		}
+
+		audit.SetAction(c, "match.report")
+		audit.SetTournament(c, tournamentID)
+		audit.SetBefore(c, before)
+		audit.SetAfter(c, after)
+
+		c.JSON(200, gin.H{"message": "Resultado reportado correctamente"})
+	})
+
+	router.GET("/api/users/:id", auth.OptionalAuthMiddleware(), func(c *gin.Context) {
+		user, ok := loadUserParam(c, "id")
+		if !ok {
+			return
+		}
+		viewerID := c.GetInt("user_id")
+
+		// Los logros forman parte de las estadísticas
+		if auth.CanViewUserSection(viewerID, user, user.Visibility.Stats) {
+			var err error
+			user.Achievements, err = database.GetUserAchievements(user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener los logros del usuario"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, models.NewPublicUser(user, auth.CanViewUserSection(viewerID, user, user.Visibility.Socials)))
+	})
+
+	router.GET("/api/auth/me", auth.AuthMiddleware(models.ScopeRead), func(c *gin.Context) {
+		c.Request.URL.Path = "/api/profile"
+		router.HandleContext(c)
+	})
+
+	router.DELETE("/api/tournaments/:id/leave", auth.AuthMiddleware(), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		userID := c.GetInt("user_id")
+
+		err = database.LeaveTournament(tournamentID, userID)
 		if err != nil {
 			c.JSON(400, gin.H{"error": err.Error()})
 			return
@@ -523,7 +952,7 @@ func main() {
 		c.JSON(200, gin.H{"message": "Te has dado de baja correctamente del torneo"})
 	})
 
-	router.POST("/api/matches/:id/upload", auth.AuthMiddleware(), func(c *gin.Context) {
+	router.POST("/api/matches/:id/upload", auth.AuthMiddleware(models.ScopeReportResults), func(c *gin.Context) {
 		// Obtener ID del match de la URL
 		idParam := c.Param("id")
 		matchID, err := strconv.Atoi(idParam)
@@ -564,10 +993,10 @@ func main() {
 		// Actualizar la columna screenshot_url en la tabla matches
 		updateQuery := `
             UPDATE matches
-            SET screenshot_url = $1
+            SET screenshot_url = $1, screenshot_uploaded_by = $3
             WHERE id = $2
         `
-		if _, err := database.DB.Exec(context.Background(), updateQuery, relativePath, matchID); err != nil {
+		if _, err := database.DB.Exec(context.Background(), updateQuery, relativePath, matchID, c.GetInt("user_id")); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al actualizar registro del match"})
 			return
 		}
@@ -579,33 +1008,58 @@ func main() {
 		})
 	})
 
-	router.GET("/api/ranking", func(c *gin.Context) {
-		ranking, err := database.GetRankingTop(10)
+	router.GET("/api/ranking", auth.OptionalAuthMiddleware(), func(c *gin.Context) {
+		filter, err := parseRankingFilter(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if !setRankingViewer(c, &filter) {
+			return
+		}
+
+		ranking, total, err := database.GetRanking(filter)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error al obtener el ranking"})
 			return
 		}
 
+		c.Header("X-Total-Count", strconv.Itoa(total))
 		c.JSON(200, ranking)
 	})
 
-	router.PUT("/api/tournaments/:id", auth.AuthMiddleware(), func(c *gin.Context) {
-		tournamentID, err := strconv.Atoi(c.Param("id"))
-		if err != nil {
-			c.JSON(400, gin.H{"error": "ID inválido"})
+	router.GET("/api/users/:id/rank", auth.OptionalAuthMiddleware(), func(c *gin.Context) {
+		user, ok := loadUserParam(c, "id")
+		if !ok || !requireUserSection(c, user, user.Visibility.Stats) {
 			return
 		}
 
-		userID := c.GetInt("user_id")
+		filter, err := parseRankingFilter(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if !setRankingViewer(c, &filter) {
+			return
+		}
 
-		tournament, err := database.GetTournamentByID(tournamentID)
+		rank, err := database.GetUserRank(user.ID, filter)
+		if errors.Is(err, database.ErrNotRanked) {
+			c.JSON(404, gin.H{"error": "El usuario no aparece en el ranking"})
+			return
+		}
 		if err != nil {
-			c.JSON(404, gin.H{"error": "Torneo no encontrado"})
+			c.JSON(500, gin.H{"error": "Error al obtener la posición en el ranking"})
 			return
 		}
 
-		if tournament.CreatedByUserID != userID {
-			c.JSON(403, gin.H{"error": "Solo el creador del torneo puede editarlo"})
+		c.JSON(200, rank)
+	})
+
+	router.PUT("/api/tournaments/:id", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.EditTournament), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
@@ -621,6 +1075,12 @@ func main() {
 			return
 		}
 
+		before, err := database.GetTournamentByID(tournamentID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Torneo no encontrado"})
+			return
+		}
+
 		// Actualizar el torneo
 		_, err = database.DB.Exec(context.Background(), `
         UPDATE tournaments
@@ -643,29 +1103,28 @@ func main() {
 			return
 		}
 
+		audit.SetAction(c, "tournament.update")
+		audit.SetBefore(c, before)
+		if after, err := database.GetTournamentByID(tournamentID); err == nil {
+			audit.SetAfter(c, after)
+		}
+
 		c.JSON(200, gin.H{"message": "Torneo actualizado correctamente"})
 	})
 
-	router.DELETE("/api/tournaments/:id", auth.AuthMiddleware(), func(c *gin.Context) {
+	router.DELETE("/api/tournaments/:id", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.DeleteTournament), func(c *gin.Context) {
 		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
 			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		userID := c.GetInt("user_id")
-
-		tournament, err := database.GetTournamentByID(tournamentID)
+		before, err := database.GetTournamentByID(tournamentID)
 		if err != nil {
 			c.JSON(404, gin.H{"error": "Torneo no encontrado"})
 			return
 		}
 
-		if tournament.CreatedByUserID != userID {
-			c.JSON(403, gin.H{"error": "Solo el creador del torneo puede eliminarlo"})
-			return
-		}
-
 		// Eliminar el torneo
 		_, err = database.DB.Exec(context.Background(), `
         DELETE FROM tournaments
@@ -677,71 +1136,1087 @@ func main() {
 			return
 		}
 
+		audit.SetAction(c, "tournament.delete")
+		audit.SetBefore(c, before)
+
 		c.JSON(200, gin.H{"message": "Torneo eliminado correctamente"})
 	})
 
-	router.GET("/api/users/:id/history", func(c *gin.Context) {
-		userID, err := strconv.Atoi(c.Param("id"))
+	router.GET("/api/tournaments/:id/staff", func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(400, gin.H{"error": "ID de usuario inválido"})
+			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		history, err := database.GetUserTournamentHistory(userID)
+		staff, err := database.GetTournamentStaff(tournamentID)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Error al obtener historial de torneos"})
+			c.JSON(500, gin.H{"error": "Error al obtener el staff del torneo"})
 			return
 		}
 
-		c.JSON(200, history)
+		c.JSON(200, staff)
 	})
 
-	router.GET("/api/users/:id/matches", func(c *gin.Context) {
-		userID, err := strconv.Atoi(c.Param("id"))
+	router.POST("/api/tournaments/:id/staff", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageStaff), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		var input struct {
+			UserID int    `json:"user_id"`
+			Role   string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil || input.UserID == 0 {
+			c.JSON(400, gin.H{"error": "Debe especificar el ID del usuario"})
+			return
+		}
+		if !auth.IsValidStaffRole(input.Role) {
+			c.JSON(400, gin.H{"error": "Rol inválido (organizer o referee)"})
+			return
+		}
+
+		if _, err := database.GetUserByID(input.UserID); err != nil {
+			c.JSON(404, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+
+		if err := database.AddTournamentStaff(tournamentID, input.UserID, input.Role, c.GetInt("user_id")); err != nil {
+			c.JSON(500, gin.H{"error": "Error al asignar el rol"})
+			return
+		}
+
+		audit.SetAction(c, "staff.add")
+		audit.SetTarget(c, "user", input.UserID)
+		audit.SetAfter(c, gin.H{"role": input.Role})
+
+		c.JSON(201, gin.H{"message": "Rol asignado correctamente"})
+	})
+
+	router.DELETE("/api/tournaments/:id/staff/:user_id", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageStaff), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+		staffUserID, err := strconv.Atoi(c.Param("user_id"))
 		if err != nil {
 			c.JSON(400, gin.H{"error": "ID de usuario inválido"})
 			return
 		}
 
-		matches, err := database.GetUserMatches(userID)
+		previousRole, _ := database.GetTournamentRole(tournamentID, staffUserID)
+
+		if err := database.RemoveTournamentStaff(tournamentID, staffUserID); err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+
+		audit.SetAction(c, "staff.remove")
+		audit.SetBefore(c, gin.H{"role": previousRole})
+
+		c.JSON(200, gin.H{"message": "Rol revocado correctamente"})
+	})
+
+	router.GET("/api/tournaments/:id/webhooks", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Error al obtener matches del usuario"})
+			c.JSON(400, gin.H{"error": "ID inválido"})
 			return
 		}
 
-		c.JSON(200, matches)
+		hooks, err := database.GetTournamentWebhooks(tournamentID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener los webhooks"})
+			return
+		}
+
+		c.JSON(200, hooks)
 	})
 
-	router.PUT("/api/profile/socials", auth.AuthMiddleware(), func(c *gin.Context) {
-		userID := c.GetInt("user_id")
+	router.POST("/api/tournaments/:id/webhooks", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
 
 		var input struct {
-			Twitch  *string `json:"twitch"`
-			YouTube *string `json:"youtube"`
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
 		}
-
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(400, gin.H{"error": "JSON inválido"})
 			return
 		}
+		if err := webhooks.ValidateURL(input.URL); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if len(input.Events) == 0 {
+			c.JSON(400, gin.H{"error": "Debe suscribirse al menos a un evento"})
+			return
+		}
+		for _, e := range input.Events {
+			if !webhooks.ValidEvent(e) {
+				c.JSON(400, gin.H{"error": "Evento desconocido: " + e})
+				return
+			}
+		}
 
-		_, err := database.DB.Exec(context.Background(), `
-        UPDATE users
-        SET twitch = $1,
-            youtube = $2
-        WHERE id = $3
-    `, input.Twitch, input.YouTube, userID)
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error generando el secreto"})
+			return
+		}
+
+		userID := c.GetInt("user_id")
+		hook := &models.Webhook{
+			TournamentID:    tournamentID,
+			URL:             input.URL,
+			Secret:          secret,
+			Events:          input.Events,
+			CreatedByUserID: &userID,
+		}
+		if err := database.CreateWebhook(hook); err != nil {
+			c.JSON(500, gin.H{"error": "Error al crear el webhook"})
+			return
+		}
+
+		// El secreto solo se muestra en esta respuesta
+		c.JSON(201, hook)
+	})
 
+	router.DELETE("/api/tournaments/:id/webhooks/:webhook_id", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			c.JSON(500, gin.H{"error": "Error al actualizar redes sociales"})
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+		webhookID, err := strconv.Atoi(c.Param("webhook_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de webhook inválido"})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Redes sociales actualizadas correctamente"})
+		if err := database.DeleteWebhook(tournamentID, webhookID); err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Webhook eliminado correctamente"})
 	})
 
-	log.Println("Servidor iniciado en el puerto 8080")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Error al iniciar el servidor: %v", err)
-	}
+	router.GET("/api/tournaments/:id/webhooks/:webhook_id/deliveries", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+		webhookID, err := strconv.Atoi(c.Param("webhook_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de webhook inválido"})
+			return
+		}
+
+		limit, offset, err := parsePagination(c, 20)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		deliveries, err := database.GetWebhookDeliveries(tournamentID, webhookID, limit, offset)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener las entregas"})
+			return
+		}
+
+		c.JSON(200, deliveries)
+	})
+
+	router.POST("/api/tournaments/:id/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+		webhookID, err := strconv.Atoi(c.Param("webhook_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de webhook inválido"})
+			return
+		}
+		deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de entrega inválido"})
+			return
+		}
+
+		delivery, err := database.RedeliverWebhook(tournamentID, webhookID, deliveryID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		webhooks.Redeliver()
+
+		c.JSON(202, delivery)
+	})
+
+	router.GET("/api/tournaments/:id/discord", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		webhookURL, err := database.GetTournamentDiscordWebhook(tournamentID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Torneo no encontrado"})
+			return
+		}
+
+		c.JSON(200, gin.H{"webhook_url": webhookURL, "configured": webhookURL != ""})
+	})
+
+	router.PUT("/api/tournaments/:id/discord", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		var input struct {
+			WebhookURL string `json:"webhook_url"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": "JSON inválido"})
+			return
+		}
+		if err := discord.ValidateWebhookURL(input.WebhookURL); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := database.SetTournamentDiscordWebhook(tournamentID, &input.WebhookURL); err != nil {
+			c.JSON(500, gin.H{"error": "Error al guardar el webhook de Discord"})
+			return
+		}
+
+		// Si el bracket aún no está generado, el torneo está en inscripciones
+		started, err := database.HasBracket(tournamentID)
+		if err == nil && !started {
+			if err := discord.AnnounceRegistration(tournamentID); err != nil {
+				log.Printf("Error anunciando inscripciones del torneo %d: %v", tournamentID, err)
+			}
+		}
+
+		// La URL permite publicar en el canal, así que no se guarda en la auditoría
+		audit.SetAction(c, "discord.set")
+
+		c.JSON(200, gin.H{"message": "Webhook de Discord configurado correctamente"})
+	})
+
+	router.DELETE("/api/tournaments/:id/discord", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageWebhooks), func(c *gin.Context) {
+		tournamentID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		if err := database.SetTournamentDiscordWebhook(tournamentID, nil); err != nil {
+			c.JSON(500, gin.H{"error": "Error al quitar el webhook de Discord"})
+			return
+		}
+
+		audit.SetAction(c, "discord.delete")
+
+		c.JSON(200, gin.H{"message": "Webhook de Discord eliminado"})
+	})
+
+	router.PUT("/api/admin/users/:id/role", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de usuario inválido"})
+			return
+		}
+
+		var input struct {
+			Role string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil || (input.Role != auth.RoleUser && input.Role != auth.RoleAdmin) {
+			c.JSON(400, gin.H{"error": "Rol inválido (user o admin)"})
+			return
+		}
+
+		previousRole, _ := database.GetUserRole(userID)
+
+		if err := database.SetUserRole(userID, input.Role); err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+
+		audit.SetAction(c, "user.role")
+		audit.SetBefore(c, gin.H{"role": previousRole})
+		audit.SetAfter(c, gin.H{"role": input.Role})
+
+		c.JSON(200, gin.H{"message": "Rol actualizado correctamente"})
+	})
+
+	// Reporte de un jugador a otro; lo revisan los administradores en /api/admin/reports
+	router.POST("/api/users/:id/report", auth.AuthMiddleware(), func(c *gin.Context) {
+		reporterID := c.GetInt("user_id")
+		reportedID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de usuario inválido"})
+			return
+		}
+		if reportedID == reporterID {
+			c.JSON(400, gin.H{"error": "No puedes reportarte a ti mismo"})
+			return
+		}
+
+		var input struct {
+			Reason       string `json:"reason"`
+			Details      string `json:"details"`
+			TournamentID *int   `json:"tournament_id"`
+			MatchID      *int   `json:"match_id"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil || !slices.Contains(models.ReportReasons, input.Reason) {
+			c.JSON(400, gin.H{"error": "Motivo inválido", "valid_reasons": models.ReportReasons})
+			return
+		}
+		if len(input.Details) > 2000 {
+			c.JSON(400, gin.H{"error": "La descripción no puede superar los 2000 caracteres"})
+			return
+		}
+
+		if _, err := database.GetUserByID(reportedID); err != nil {
+			c.JSON(404, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+
+		report := models.UserReport{
+			ReporterID:     &reporterID,
+			ReportedUserID: reportedID,
+			TournamentID:   input.TournamentID,
+			MatchID:        input.MatchID,
+			Reason:         input.Reason,
+			Details:        input.Details,
+		}
+		if err := database.CreateUserReport(&report); err != nil {
+			if errors.Is(err, database.ErrReportPending) {
+				c.JSON(409, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Error al crear el reporte"})
+			return
+		}
+
+		c.JSON(201, report)
+	})
+
+	// Cola de reportes; por defecto solo los pendientes (?status=all para todos)
+	router.GET("/api/admin/reports", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		limit, offset, err := parsePagination(c, 20)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		status := c.DefaultQuery("status", models.ReportPending)
+		if status == "all" {
+			status = ""
+		} else if status != models.ReportPending && status != models.ReportDismissed && status != models.ReportActioned {
+			c.JSON(400, gin.H{"error": "Estado inválido"})
+			return
+		}
+
+		reports, total, err := database.GetUserReports(status, limit, offset)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener los reportes"})
+			return
+		}
+
+		c.JSON(200, gin.H{"reports": reports, "total": total, "limit": limit, "offset": offset})
+	})
+
+	router.POST("/api/admin/reports/:report_id/dismiss", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		reportID, err := strconv.Atoi(c.Param("report_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de reporte inválido"})
+			return
+		}
+
+		var input struct {
+			Resolution string `json:"resolution"`
+		}
+		c.ShouldBindJSON(&input)
+
+		if err := database.DismissReport(reportID, c.GetInt("user_id"), input.Resolution); err != nil {
+			if errors.Is(err, database.ErrReportNotPending) {
+				c.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Error al revisar el reporte"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Reporte descartado"})
+	})
+
+	// Resuelve un reporte sancionando al usuario reportado. La sanción es global salvo
+	// que se indique tournament_id.
+	router.POST("/api/admin/reports/:report_id/ban", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		reportID, err := strconv.Atoi(c.Param("report_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de reporte inválido"})
+			return
+		}
+
+		userID, _, err := database.GetPendingReportTarget(reportID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Reporte no encontrado o ya revisado"})
+			return
+		}
+
+		ban, ttl, err := bindBan(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		ban.UserID = userID
+
+		if err := database.CreateBan(ban, ttl, &reportID); err != nil {
+			if errors.Is(err, database.ErrReportNotPending) || errors.Is(err, database.ErrBanTournamentMissing) {
+				c.JSON(404, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Error al aplicar la sanción"})
+			return
+		}
+		disconnectIfSiteBan(ban)
+
+		c.JSON(201, ban)
+	})
+
+	router.GET("/api/admin/bans", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		filter := database.BanFilter{ActiveOnly: c.Query("active") == "true"}
+
+		var err error
+		filter.Limit, filter.Offset, err = parsePagination(c, 20)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if v := c.Query("user_id"); v != "" {
+			if filter.UserID, err = strconv.Atoi(v); err != nil {
+				c.JSON(400, gin.H{"error": "user_id inválido"})
+				return
+			}
+		}
+		if v := c.Query("tournament_id"); v != "" {
+			if filter.TournamentID, err = strconv.Atoi(v); err != nil {
+				c.JSON(400, gin.H{"error": "tournament_id inválido"})
+				return
+			}
+		}
+		filter.SiteOnly = c.Query("scope") == "site"
+
+		bans, err := database.GetBans(filter)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener las sanciones"})
+			return
+		}
+		c.JSON(200, bans)
+	})
+
+	router.POST("/api/admin/bans", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		ban, ttl, err := bindBan(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if ban.UserID == 0 {
+			c.JSON(400, gin.H{"error": "Debe especificar el usuario"})
+			return
+		}
+		if _, err := database.GetUserByID(ban.UserID); err != nil {
+			c.JSON(404, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+
+		if err := database.CreateBan(ban, ttl, nil); err != nil {
+			if errors.Is(err, database.ErrBanTournamentMissing) {
+				c.JSON(404, gin.H{"error": "Torneo no encontrado"})
+				return
+			}
+			c.JSON(500, gin.H{"error": "Error al aplicar la sanción"})
+			return
+		}
+		disconnectIfSiteBan(ban)
+		c.JSON(201, ban)
+	})
+
+	router.DELETE("/api/admin/bans/:ban_id", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		banID, err := strconv.Atoi(c.Param("ban_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de sanción inválido"})
+			return
+		}
+
+		if err := database.RevokeBan(banID, nil, c.GetInt("user_id")); err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"message": "Sanción levantada"})
+	})
+
+	// Historial de sanciones y revisiones; ?user_id= para el de un usuario
+	router.GET("/api/admin/moderation-log", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		limit, offset, err := parsePagination(c, 50)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := 0
+		if v := c.Query("user_id"); v != "" {
+			if userID, err = strconv.Atoi(v); err != nil {
+				c.JSON(400, gin.H{"error": "user_id inválido"})
+				return
+			}
+		}
+
+		actions, err := database.GetModerationActions(userID, limit, offset)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener el historial"})
+			return
+		}
+		c.JSON(200, actions)
+	})
+
+	// Registro de auditoría del torneo. Admite los filtros de /api/admin/audit salvo tournament_id.
+	router.GET("/api/tournaments/:id/audit", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ViewAudit), func(c *gin.Context) {
+		filter, err := parseAuditFilter(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		filter.TournamentID, _ = strconv.Atoi(c.Param("id"))
+
+		entries, total, err := database.GetAuditLog(filter)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener la auditoría"})
+			return
+		}
+		c.JSON(200, gin.H{"entries": entries, "total": total, "limit": filter.Limit, "offset": filter.Offset})
+	})
+
+	// Registro de auditoría global. Filtros: actor_id, tournament_id, action, target_type,
+	// target_id, from y to (RFC3339), más limit y offset.
+	router.GET("/api/admin/audit", auth.AuthMiddleware(), auth.RequireAdmin(), func(c *gin.Context) {
+		filter, err := parseAuditFilter(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if v := c.Query("tournament_id"); v != "" {
+			if filter.TournamentID, err = strconv.Atoi(v); err != nil {
+				c.JSON(400, gin.H{"error": "tournament_id inválido"})
+				return
+			}
+		}
+
+		entries, total, err := database.GetAuditLog(filter)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener la auditoría"})
+			return
+		}
+		c.JSON(200, gin.H{"entries": entries, "total": total, "limit": filter.Limit, "offset": filter.Offset})
+	})
+
+	// Sanciones de un torneo, gestionadas por sus organizadores
+	router.GET("/api/tournaments/:id/bans", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageBans), func(c *gin.Context) {
+		tournamentID, _ := strconv.Atoi(c.Param("id"))
+
+		limit, offset, err := parsePagination(c, 50)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		bans, err := database.GetBans(database.BanFilter{
+			TournamentID: tournamentID,
+			ActiveOnly:   c.Query("active") == "true",
+			Limit:        limit,
+			Offset:       offset,
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener las sanciones"})
+			return
+		}
+		c.JSON(200, bans)
+	})
+
+	router.POST("/api/tournaments/:id/bans", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageBans), func(c *gin.Context) {
+		tournamentID, _ := strconv.Atoi(c.Param("id"))
+
+		ban, ttl, err := bindBan(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if ban.UserID == 0 {
+			c.JSON(400, gin.H{"error": "Debe especificar el usuario"})
+			return
+		}
+		if ban.UserID == c.GetInt("user_id") {
+			c.JSON(400, gin.H{"error": "No puedes sancionarte a ti mismo"})
+			return
+		}
+		if _, err := database.GetUserByID(ban.UserID); err != nil {
+			c.JSON(404, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+		allowed, err := auth.CanBanFromTournament(c.GetInt("user_id"), ban.UserID, tournamentID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al comprobar los permisos"})
+			return
+		}
+		if !allowed {
+			c.JSON(403, gin.H{"error": "Solo el dueño del torneo o un administrador pueden sancionar al staff"})
+			return
+		}
+		ban.TournamentID = &tournamentID
+
+		if err := database.CreateBan(ban, ttl, nil); err != nil {
+			c.JSON(500, gin.H{"error": "Error al aplicar la sanción"})
+			return
+		}
+		c.JSON(201, ban)
+	})
+
+	router.DELETE("/api/tournaments/:id/bans/:ban_id", auth.AuthMiddleware(models.ScopeManageTournaments), auth.RequireTournamentPermission(auth.ManageBans), func(c *gin.Context) {
+		tournamentID, _ := strconv.Atoi(c.Param("id"))
+		banID, err := strconv.Atoi(c.Param("ban_id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID de sanción inválido"})
+			return
+		}
+
+		if err := database.RevokeBan(banID, &tournamentID, c.GetInt("user_id")); err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"message": "Sanción levantada"})
+	})
+
+	router.GET("/api/users/:id/history", auth.OptionalAuthMiddleware(), func(c *gin.Context) {
+		user, ok := loadUserParam(c, "id")
+		if !ok || !requireUserSection(c, user, user.Visibility.MatchHistory) {
+			return
+		}
+
+		history, err := database.GetUserTournamentHistory(user.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener historial de torneos"})
+			return
+		}
+
+		c.JSON(200, history)
+	})
+
+	router.GET("/api/users/:id/matches", auth.OptionalAuthMiddleware(), func(c *gin.Context) {
+		user, ok := loadUserParam(c, "id")
+		if !ok || !requireUserSection(c, user, user.Visibility.MatchHistory) {
+			return
+		}
+
+		matches, err := database.GetUserMatches(user.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener matches del usuario"})
+			return
+		}
+
+		c.JSON(200, matches)
+	})
+
+	router.GET("/api/users/:id/stats", auth.OptionalAuthMiddleware(), func(c *gin.Context) {
+		user, ok := loadUserParam(c, "id")
+		if !ok || !requireUserSection(c, user, user.Visibility.Stats) {
+			return
+		}
+
+		stats, err := database.GetUserStats(user.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener estadísticas del usuario"})
+			return
+		}
+
+		c.JSON(200, stats)
+	})
+
+	// El historial entre dos jugadores revela partidas de ambos: tienen que ser visibles los dos
+	router.GET("/api/users/:id/vs/:other", auth.OptionalAuthMiddleware(), func(c *gin.Context) {
+		userA, ok := loadUserParam(c, "id")
+		if !ok {
+			return
+		}
+		userB, ok := loadUserParam(c, "other")
+		if !ok {
+			return
+		}
+		if userA.ID == userB.ID {
+			c.JSON(400, gin.H{"error": "ID de rival inválido"})
+			return
+		}
+		if !requireUserSection(c, userA, userA.Visibility.MatchHistory) ||
+			!requireUserSection(c, userB, userB.Visibility.MatchHistory) {
+			return
+		}
+
+		h2h, err := database.GetHeadToHead(userA.ID, userB.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener el historial entre jugadores"})
+			return
+		}
+
+		c.JSON(200, h2h)
+	})
+
+	router.PUT("/api/profile/socials", auth.AuthMiddleware(), func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+
+		var input struct {
+			Twitch  *string `json:"twitch"`
+			YouTube *string `json:"youtube"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": "JSON inválido"})
+			return
+		}
+
+		before, err := database.GetUserByID(userID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Usuario no encontrado"})
+			return
+		}
+
+		_, err = database.DB.Exec(context.Background(), `
+        UPDATE users
+        SET twitch = $1,
+            youtube = $2
+        WHERE id = $3
+    `, input.Twitch, input.YouTube, userID)
+
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al actualizar redes sociales"})
+			return
+		}
+
+		audit.SetAction(c, "profile.socials")
+		audit.SetTarget(c, "user", userID)
+		audit.SetBefore(c, gin.H{"twitch": before.Twitch, "youtube": before.YouTube})
+		audit.SetAfter(c, gin.H{"twitch": input.Twitch, "youtube": input.YouTube})
+
+		c.JSON(200, gin.H{"message": "Redes sociales actualizadas correctamente"})
+	})
+
+	router.GET("/api/notifications", auth.AuthMiddleware(models.ScopeRead), func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+
+		limit, offset, err := parsePagination(c, 20)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		notifications, total, unread, err := database.GetNotifications(userID, limit, offset, c.Query("unread") == "true")
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener las notificaciones"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"notifications": notifications,
+			"total":         total,
+			"unread_count":  unread,
+		})
+	})
+
+	router.POST("/api/notifications/:id/read", auth.AuthMiddleware(), func(c *gin.Context) {
+		notificationID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "ID inválido"})
+			return
+		}
+
+		if err := database.MarkNotificationRead(c.GetInt("user_id"), notificationID); err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": "Notificación marcada como leída"})
+	})
+
+	router.POST("/api/notifications/read-all", auth.AuthMiddleware(), func(c *gin.Context) {
+		marked, err := database.MarkAllNotificationsRead(c.GetInt("user_id"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al marcar las notificaciones"})
+			return
+		}
+
+		c.JSON(200, gin.H{"marked": marked})
+	})
+
+	router.GET("/api/notifications/preferences", auth.AuthMiddleware(models.ScopeRead), func(c *gin.Context) {
+		prefs, err := database.GetNotificationPreferences(c.GetInt("user_id"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener las preferencias"})
+			return
+		}
+
+		c.JSON(200, prefs)
+	})
+
+	router.PUT("/api/notifications/preferences", auth.AuthMiddleware(), func(c *gin.Context) {
+		var input []models.NotificationPreference
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(400, gin.H{"error": "JSON inválido"})
+			return
+		}
+
+		for _, p := range input {
+			if !slices.Contains(models.NotificationTypes, p.Type) {
+				c.JSON(400, gin.H{"error": "Tipo de notificación desconocido: " + p.Type})
+				return
+			}
+		}
+
+		userID := c.GetInt("user_id")
+		if err := database.SetNotificationPreferences(userID, input); err != nil {
+			c.JSON(500, gin.H{"error": "Error al guardar las preferencias"})
+			return
+		}
+
+		prefs, err := database.GetNotificationPreferences(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error al obtener las preferencias"})
+			return
+		}
+
+		c.JSON(200, prefs)
+	})
+
+	srv := &http.Server{
+		Addr:    ":8080",
+		Handler: router,
+	}
+
+	go func() {
+		log.Println("Servidor iniciado en el puerto 8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error al iniciar el servidor: %v", err)
+		}
+	}()
+
+	// Apagado ordenado: cerrar los sockets y esperar a las peticiones en curso
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Apagando el servidor...")
+	realtime.Shutdown()
+	webhooks.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Error apagando el servidor: %v", err)
+	}
+}
+
+// parseRankingFilter lee los filtros y la paginación del ranking de la query string
+func parseRankingFilter(c *gin.Context) (database.RankingFilter, error) {
+	filter := database.RankingFilter{
+		Game:     c.Query("game"),
+		Platform: c.Query("platform"),
+	}
+
+	var err error
+	filter.Limit, filter.Offset, err = parsePagination(c, 10)
+	return filter, err
+}
+
+// setRankingViewer añade al filtro quién consulta el ranking, que decide qué usuarios
+// aparecen según la visibilidad de sus estadísticas
+func setRankingViewer(c *gin.Context, filter *database.RankingFilter) bool {
+	filter.ViewerID = c.GetInt("user_id")
+	if filter.ViewerID == 0 {
+		return true
+	}
+
+	admin, err := auth.IsAdmin(filter.ViewerID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Error al obtener el ranking"})
+		return false
+	}
+	filter.ViewerIsAdmin = admin
+	return true
+}
+
+// parsePagination lee limit (1-100) y offset de la query string
+func parsePagination(c *gin.Context, defaultLimit int) (int, int, error) {
+	limit, offset := defaultLimit, 0
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			return 0, 0, fmt.Errorf("limit debe estar entre 1 y 100")
+		}
+		limit = n
+	}
+
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset inválido")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
+// disconnectIfSiteBan cierra las conexiones en tiempo real del usuario si la sanción es
+// global: sus tokens dejan de ser válidos y los sockets no deben seguir autenticados.
+func disconnectIfSiteBan(ban *models.Ban) {
+	if ban.TournamentID == nil {
+		realtime.DisconnectUser(ban.UserID)
+	}
+}
+
+// bindBan lee una sanción del cuerpo: user_id, tournament_id (opcional), reason y
+// expires_in_days (0 o ausente = sin caducidad). El autor es el usuario autenticado.
+func bindBan(c *gin.Context) (*models.Ban, time.Duration, error) {
+	var input struct {
+		UserID        int    `json:"user_id"`
+		TournamentID  *int   `json:"tournament_id"`
+		Reason        string `json:"reason"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		return nil, 0, fmt.Errorf("datos inválidos")
+	}
+	if input.Reason == "" || len(input.Reason) > 500 {
+		return nil, 0, fmt.Errorf("el motivo es obligatorio (máximo 500 caracteres)")
+	}
+	if input.ExpiresInDays < 0 {
+		return nil, 0, fmt.Errorf("caducidad inválida")
+	}
+
+	actorID := c.GetInt("user_id")
+	return &models.Ban{
+		UserID:          input.UserID,
+		TournamentID:    input.TournamentID,
+		Reason:          input.Reason,
+		CreatedByUserID: &actorID,
+	}, time.Duration(input.ExpiresInDays) * 24 * time.Hour, nil
+}
+
+func parseAuditFilter(c *gin.Context) (database.AuditFilter, error) {
+	filter := database.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+	}
+
+	var err error
+	filter.Limit, filter.Offset, err = parsePagination(c, 50)
+	if err != nil {
+		return filter, err
+	}
+
+	if v := c.Query("actor_id"); v != "" {
+		if filter.ActorUserID, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("actor_id inválido")
+		}
+	}
+	if v := c.Query("target_id"); v != "" {
+		if filter.TargetID, err = strconv.Atoi(v); err != nil {
+			return filter, fmt.Errorf("target_id inválido")
+		}
+	}
+	for param, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("%s debe tener formato RFC3339", param)
+			}
+			t = t.UTC()
+			*dst = &t
+		}
+	}
+
+	return filter, nil
+}
+
+// loadUserParam carga el usuario del parámetro indicado, respondiendo 400 o 404 si falla
+func loadUserParam(c *gin.Context, param string) (*models.User, bool) {
+	id, err := strconv.Atoi(c.Param(param))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "ID de usuario inválido"})
+		return nil, false
+	}
+
+	user, err := database.GetUserByID(id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Usuario no encontrado"})
+		return nil, false
+	}
+	return user, true
+}
+
+// requireUserSection responde 403 si quien consulta no puede ver esa sección del perfil
+func requireUserSection(c *gin.Context, user *models.User, level string) bool {
+	if !auth.CanViewUserSection(c.GetInt("user_id"), user, level) {
+		c.JSON(403, gin.H{"error": "El usuario no comparte esta información"})
+		return false
+	}
+	return true
+}
+
+// writeExportZip escribe la exportación de un usuario como ZIP: data.json con sus datos y
+// screenshots/ con las capturas que siguen en disco
+func writeExportZip(w io.Writer, export *models.UserExport) error {
+	zw := zip.NewWriter(w)
+
+	data, err := zw.Create("data.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(data)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+
+	for _, s := range export.Screenshots {
+		name := filepath.Base(s.URL)
+		f, err := os.Open(filepath.Join("./uploads", name))
+		if err != nil {
+			continue
+		}
+		dst, err := zw.Create("screenshots/" + name)
+		if err == nil {
+			_, err = io.Copy(dst, f)
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
 }
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_achievements (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code VARCHAR(50) NOT NULL,
+  tournament_id INTEGER REFERENCES tournaments(id) ON DELETE SET NULL,
+  unlocked_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(user_id, code)
+);
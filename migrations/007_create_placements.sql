@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS placements (
+  id SERIAL PRIMARY KEY,
+  tournament_id INTEGER NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  placement INTEGER NOT NULL,
+  placement_max INTEGER NOT NULL,
+  round_reached INTEGER NOT NULL DEFAULT 0,
+  UNIQUE(tournament_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_placements_user ON placements(user_id);
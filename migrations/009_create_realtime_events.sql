@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS realtime_events (
+  id BIGSERIAL PRIMARY KEY,
+  data JSONB NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_realtime_events_created_at ON realtime_events(created_at);
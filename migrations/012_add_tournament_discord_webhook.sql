@@ -0,0 +1 @@
+ALTER TABLE tournaments ADD COLUMN IF NOT EXISTS discord_webhook_url TEXT;
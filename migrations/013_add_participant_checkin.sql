@@ -0,0 +1 @@
+ALTER TABLE participants ADD COLUMN IF NOT EXISTS checked_in_at TIMESTAMP;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider VARCHAR(50) NOT NULL,
+  provider_user_id VARCHAR(255) NOT NULL,
+  username VARCHAR(255),
+  email VARCHAR(255),
+  avatar_url TEXT,
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(provider, provider_user_id),
+  UNIQUE(user_id, provider)
+);
+
+-- Las cuentas creadas antes de esta migración guardaban su único proveedor en users
+INSERT INTO user_identities (user_id, provider, provider_user_id, username, email, avatar_url, created_at)
+SELECT id, oauth_provider, oauth_id, username, email, avatar_url, created_at
+FROM users
+WHERE oauth_provider IS NOT NULL AND oauth_id IS NOT NULL AND oauth_id <> ''
+ON CONFLICT DO NOTHING;
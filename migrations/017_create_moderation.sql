@@ -0,0 +1,51 @@
+-- Sanciones: sin tournament_id la sanción es global (no puede iniciar sesión); con
+-- tournament_id solo impide inscribirse y reportar en ese torneo
+CREATE TABLE IF NOT EXISTS bans (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  tournament_id INTEGER REFERENCES tournaments(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL,
+  expires_at TIMESTAMP,
+  created_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  revoked_at TIMESTAMP,
+  revoked_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bans_user ON bans(user_id);
+CREATE INDEX IF NOT EXISTS idx_bans_tournament ON bans(tournament_id);
+
+CREATE TABLE IF NOT EXISTS user_reports (
+  id SERIAL PRIMARY KEY,
+  reporter_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  reported_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  tournament_id INTEGER REFERENCES tournaments(id) ON DELETE SET NULL,
+  match_id INTEGER REFERENCES matches(id) ON DELETE SET NULL,
+  reason VARCHAR(50) NOT NULL,
+  details TEXT,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  reviewed_by_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  reviewed_at TIMESTAMP,
+  resolution TEXT,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_reports_status ON user_reports(status, created_at);
+-- Un usuario no puede reportar dos veces a otro mientras el primer reporte esté pendiente
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_reports_pending
+  ON user_reports(reporter_id, reported_user_id) WHERE status = 'pending';
+
+-- Historial de sanciones y revisiones de reportes
+CREATE TABLE IF NOT EXISTS moderation_actions (
+  id SERIAL PRIMARY KEY,
+  actor_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  action VARCHAR(30) NOT NULL,
+  target_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  tournament_id INTEGER REFERENCES tournaments(id) ON DELETE SET NULL,
+  ban_id INTEGER REFERENCES bans(id) ON DELETE SET NULL,
+  report_id INTEGER REFERENCES user_reports(id) ON DELETE SET NULL,
+  details TEXT,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target ON moderation_actions(target_user_id, created_at);
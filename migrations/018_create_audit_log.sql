@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  actor_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  -- La petición se autenticó con un token de API en lugar de una sesión
+  via_api_token BOOLEAN NOT NULL DEFAULT FALSE,
+  action VARCHAR(100) NOT NULL,
+  method VARCHAR(10) NOT NULL,
+  path TEXT NOT NULL,
+  target_type VARCHAR(50),
+  target_id INTEGER,
+  -- Torneo afectado, para el registro por torneo; sin FK para conservarlo al borrarlo
+  tournament_id INTEGER,
+  -- Solo los campos que cambian: valores anteriores y nuevos
+  before JSONB,
+  after JSONB,
+  ip VARCHAR(64),
+  status_code INTEGER NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_tournament ON audit_log(tournament_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
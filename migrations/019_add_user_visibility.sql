@@ -0,0 +1,7 @@
+-- Quién puede ver cada parte del perfil: public, registered (usuarios con sesión) o private
+ALTER TABLE users ADD COLUMN IF NOT EXISTS match_history_visibility VARCHAR(20) NOT NULL DEFAULT 'public'
+  CHECK (match_history_visibility IN ('public', 'registered', 'private'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS socials_visibility VARCHAR(20) NOT NULL DEFAULT 'public'
+  CHECK (socials_visibility IN ('public', 'registered', 'private'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS stats_visibility VARCHAR(20) NOT NULL DEFAULT 'public'
+  CHECK (stats_visibility IN ('public', 'registered', 'private'));
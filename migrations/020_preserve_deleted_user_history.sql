@@ -0,0 +1,27 @@
+-- Fecha en que se borró (anonimizó) la cuenta
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Las cuentas borradas se anonimizan en lugar de eliminarse (ver DELETE /api/profile).
+-- Las inscripciones y clasificaciones ya no se borran en cascada con el usuario: un
+-- borrado directo de la fila falla en lugar de romper los brackets de los demás, igual
+-- que ya ocurre con las referencias de matches.
+DO $$
+DECLARE
+  fk RECORD;
+BEGIN
+  FOR fk IN
+    SELECT c.conrelid::regclass::text AS tbl, c.conname
+    FROM pg_constraint c
+    WHERE c.contype = 'f'
+      AND c.confrelid = 'users'::regclass
+      AND c.conrelid IN ('participants'::regclass, 'placements'::regclass)
+      AND c.confdeltype = 'c'
+  LOOP
+    EXECUTE format('ALTER TABLE %I DROP CONSTRAINT %I', fk.tbl, fk.conname);
+    EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT',
+                   fk.tbl, fk.conname);
+  END LOOP;
+END $$;
+
+-- Quién subió cada captura, para incluirlas en la exportación de datos del usuario
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS screenshot_uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
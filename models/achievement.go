@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type Achievement struct {
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	TournamentID *int      `json:"tournament_id,omitempty"`
+	UnlockedAt   time.Time `json:"unlocked_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Permisos que puede tener un token de API
+const (
+	ScopeRead              = "read"
+	ScopeReportResults     = "report_results"
+	ScopeManageTournaments = "manage_tournaments"
+)
+
+var APITokenScopes = []string{
+	ScopeRead,
+	ScopeReportResults,
+	ScopeManageTournaments,
+}
+
+type APIToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Token      string     `json:"token,omitempty"` // solo se devuelve al crearlo
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
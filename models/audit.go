@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEntry struct {
+	ID           int64           `json:"id"`
+	ActorUserID  *int            `json:"actor_user_id,omitempty"`
+	ActorName    string          `json:"actor_username,omitempty"`
+	ViaAPIToken  bool            `json:"via_api_token"`
+	Action       string          `json:"action"`
+	Method       string          `json:"method"`
+	Path         string          `json:"path"`
+	TargetType   string          `json:"target_type,omitempty"`
+	TargetID     *int            `json:"target_id,omitempty"`
+	TournamentID *int            `json:"tournament_id,omitempty"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	IP           string          `json:"ip"`
+	StatusCode   int             `json:"status_code"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
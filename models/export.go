@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// PointsEntry son los puntos de ranking obtenidos en un torneo
+type PointsEntry struct {
+	TournamentID int       `json:"tournament_id"`
+	Name         string    `json:"name"`
+	Game         string    `json:"game"`
+	StartTime    time.Time `json:"start_time"`
+	Points       int       `json:"points"`
+}
+
+// Screenshot es una captura de resultado subida por el usuario
+type Screenshot struct {
+	MatchID      int    `json:"match_id"`
+	TournamentID int    `json:"tournament_id"`
+	URL          string `json:"url"`
+}
+
+// UserExport reúne todos los datos de un usuario para GET /api/profile/export
+type UserExport struct {
+	ExportedAt     time.Time                `json:"exported_at"`
+	Profile        PrivateUser              `json:"profile"`
+	Identities     []UserIdentity           `json:"identities"`
+	Achievements   []Achievement            `json:"achievements"`
+	Participations []map[string]interface{} `json:"participations"`
+	Matches        []map[string]interface{} `json:"matches"`
+	Points         int                      `json:"points"`
+	PointsHistory  []PointsEntry            `json:"points_history"`
+	Screenshots    []Screenshot             `json:"screenshots"`
+}
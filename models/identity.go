@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UserIdentity es una cuenta externa (Discord, Twitch, Google, Steam) vinculada a un usuario
+type UserIdentity struct {
+	Provider       string    `json:"provider"`
+	ProviderUserID string    `json:"provider_user_id"`
+	Username       string    `json:"username"`
+	Email          string    `json:"email,omitempty"`
+	AvatarURL      string    `json:"avatar_url"`
+	CreatedAt      time.Time `json:"created_at"`
+}
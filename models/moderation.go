@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Motivos de un reporte de usuario
+const (
+	ReportCheating      = "cheating"
+	ReportToxicity      = "toxicity"
+	ReportNoShow        = "no_show"
+	ReportImpersonation = "impersonation"
+	ReportOther         = "other"
+)
+
+var ReportReasons = []string{ReportCheating, ReportToxicity, ReportNoShow, ReportImpersonation, ReportOther}
+
+// Estados de un reporte
+const (
+	ReportPending   = "pending"
+	ReportDismissed = "dismissed"
+	ReportActioned  = "actioned"
+)
+
+// Acciones del historial de moderación
+const (
+	ModerationBan           = "ban"
+	ModerationUnban         = "unban"
+	ModerationReportDismiss = "report_dismissed"
+)
+
+// Ban es una sanción. Sin TournamentID es global.
+type Ban struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	Username        string     `json:"username,omitempty"`
+	TournamentID    *int       `json:"tournament_id,omitempty"`
+	Reason          string     `json:"reason"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	CreatedByUserID *int       `json:"created_by_user_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	RevokedByUserID *int       `json:"revoked_by_user_id,omitempty"`
+	Active          bool       `json:"active"`
+}
+
+type UserReport struct {
+	ID               int        `json:"id"`
+	ReporterID       *int       `json:"reporter_id,omitempty"`
+	ReporterUsername string     `json:"reporter_username,omitempty"`
+	ReportedUserID   int        `json:"reported_user_id"`
+	ReportedUsername string     `json:"reported_username,omitempty"`
+	TournamentID     *int       `json:"tournament_id,omitempty"`
+	MatchID          *int       `json:"match_id,omitempty"`
+	Reason           string     `json:"reason"`
+	Details          string     `json:"details,omitempty"`
+	Status           string     `json:"status"`
+	ReviewedByUserID *int       `json:"reviewed_by_user_id,omitempty"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	Resolution       string     `json:"resolution,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type ModerationAction struct {
+	ID           int       `json:"id"`
+	ActorUserID  *int      `json:"actor_user_id,omitempty"`
+	Action       string    `json:"action"`
+	TargetUserID int       `json:"target_user_id"`
+	TournamentID *int      `json:"tournament_id,omitempty"`
+	BanID        *int      `json:"ban_id,omitempty"`
+	ReportID     *int      `json:"report_id,omitempty"`
+	Details      string    `json:"details,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
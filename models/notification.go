@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Tipos de notificación; cada usuario puede desactivarlos por separado
+const (
+	NotificationMatchReady          = "match_ready"
+	NotificationOpponentReported    = "opponent_reported"
+	NotificationMatchResult         = "match_result"
+	NotificationBracketGenerated    = "bracket_generated"
+	NotificationTournamentFinished  = "tournament_finished"
+	NotificationAchievementUnlocked = "achievement_unlocked"
+)
+
+var NotificationTypes = []string{
+	NotificationMatchReady,
+	NotificationOpponentReported,
+	NotificationMatchResult,
+	NotificationBracketGenerated,
+	NotificationTournamentFinished,
+	NotificationAchievementUnlocked,
+}
+
+type Notification struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	Type         string     `json:"type"`
+	Title        string     `json:"title"`
+	Body         string     `json:"body"`
+	TournamentID *int       `json:"tournament_id,omitempty"`
+	MatchID      *int       `json:"match_id,omitempty"`
+	ReadAt       *time.Time `json:"read_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type NotificationPreference struct {
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
@@ -0,0 +1,11 @@
+package models
+
+type Placement struct {
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	AvatarURL    string `json:"avatar_url"`
+	Placement    int    `json:"placement"`
+	PlacementMax int    `json:"placement_max"`
+	Label        string `json:"label"`
+	RoundReached int    `json:"round_reached"`
+}
@@ -2,14 +2,110 @@ package models
 
 import "time"
 
+// User es el usuario completo. Los datos de cuenta no se serializan: las respuestas de
+// la API usan PublicUser o PrivateUser.
 type User struct {
 	ID            int       `json:"id"`
 	Username      string    `json:"username"`
-	Email         string    `json:"email"`
-	OAuthProvider string    `json:"oauth_provider"`
-	OAuthID       string    `json:"oauth_id"`
+	Email         string    `json:"-"`
+	OAuthProvider string    `json:"-"`
+	OAuthID       string    `json:"-"`
 	AvatarURL     string    `json:"avatar_url"`
 	CreatedAt     time.Time `json:"created_at"`
 	Twitch        *string   `json:"twitch"`
 	YouTube       *string   `json:"youtube"`
+
+	Visibility UserVisibility `json:"-"`
+
+	Achievements []Achievement `json:"achievements,omitempty"`
+}
+
+// DeletedUsername sustituye el nombre de las cuentas borradas, que se anonimizan para
+// no romper los brackets ni el historial de sus rivales
+const DeletedUsername = "Usuario eliminado"
+
+// Niveles de visibilidad de una sección del perfil
+const (
+	VisibilityPublic     = "public"
+	VisibilityRegistered = "registered"
+	VisibilityPrivate    = "private"
+)
+
+func ValidVisibility(v string) bool {
+	return v == VisibilityPublic || v == VisibilityRegistered || v == VisibilityPrivate
+}
+
+// UserVisibility indica quién puede ver cada sección del perfil
+type UserVisibility struct {
+	MatchHistory string `json:"match_history"`
+	Socials      string `json:"socials"`
+	Stats        string `json:"stats"`
+}
+
+// Allows indica si viewerID (0 = sin sesión) puede ver una sección con ese nivel.
+// El propio usuario siempre puede.
+func Allows(level string, viewerID, ownerID int) bool {
+	switch {
+	case viewerID != 0 && viewerID == ownerID:
+		return true
+	case level == VisibilityPublic:
+		return true
+	case level == VisibilityRegistered:
+		return viewerID != 0
+	default:
+		return false
+	}
+}
+
+// PublicUser es lo que ve cualquiera del perfil de otro usuario. Las redes y los logros
+// solo se incluyen si su visibilidad lo permite.
+type PublicUser struct {
+	ID           int           `json:"id"`
+	Username     string        `json:"username"`
+	AvatarURL    string        `json:"avatar_url"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Twitch       *string       `json:"twitch,omitempty"`
+	YouTube      *string       `json:"youtube,omitempty"`
+	Achievements []Achievement `json:"achievements,omitempty"`
+}
+
+func NewPublicUser(u *User, showSocials bool) PublicUser {
+	p := PublicUser{
+		ID:           u.ID,
+		Username:     u.Username,
+		AvatarURL:    u.AvatarURL,
+		CreatedAt:    u.CreatedAt,
+		Achievements: u.Achievements,
+	}
+	if showSocials {
+		p.Twitch, p.YouTube = u.Twitch, u.YouTube
+	}
+	return p
+}
+
+// PrivateUser es el perfil que ve el propio usuario (y los administradores)
+type PrivateUser struct {
+	ID         int            `json:"id"`
+	Role       string         `json:"role"`
+	Username   string         `json:"username"`
+	Email      string         `json:"email"`
+	Avatar     string         `json:"avatar"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	Twitch     *string        `json:"twitch"`
+	YouTube    *string        `json:"youtube"`
+	Visibility UserVisibility `json:"visibility"`
+}
+
+func NewPrivateUser(u *User, role string) PrivateUser {
+	return PrivateUser{
+		ID:         u.ID,
+		Role:       role,
+		Username:   u.Username,
+		Email:      u.Email,
+		Avatar:     u.AvatarURL,
+		CreatedAt:  u.CreatedAt,
+		Twitch:     u.Twitch,
+		YouTube:    u.YouTube,
+		Visibility: u.Visibility,
+	}
 }
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Eventos a los que puede suscribirse un webhook
+const (
+	WebhookBracketGenerated   = "bracket.generated"
+	WebhookMatchCompleted     = "match.completed"
+	WebhookTournamentFinished = "tournament.finished"
+	WebhookParticipantJoined  = "participant.joined"
+)
+
+var WebhookEvents = []string{
+	WebhookBracketGenerated,
+	WebhookMatchCompleted,
+	WebhookTournamentFinished,
+	WebhookParticipantJoined,
+}
+
+type Webhook struct {
+	ID              int       `json:"id"`
+	TournamentID    int       `json:"tournament_id"`
+	URL             string    `json:"url"`
+	Secret          string    `json:"secret,omitempty"` // solo se devuelve al crearlo
+	Events          []string  `json:"events"`
+	Active          bool      `json:"active"`
+	CreatedByUserID *int      `json:"created_by_user_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+
+	// Destino, cargado al reclamar la entrega para enviarla
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
@@ -0,0 +1,102 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Broker reparte los eventos publicados entre todas las instancias del backend.
+// Cada instancia entrega a sus propios clientes los eventos que recibe del broker,
+// así un resultado reportado en una réplica llega a los sockets conectados a otra.
+type Broker interface {
+	// Publish envía el evento a todas las instancias, incluida la actual
+	Publish(ctx context.Context, event Event) error
+	// Start empieza a entregar al handler los eventos publicados por cualquier instancia.
+	// El broker asigna el ID de secuencia antes de entregar el evento.
+	Start(handler func(Event)) error
+	Close() error
+}
+
+var (
+	brokerMu sync.RWMutex
+	broker   Broker
+)
+
+func init() {
+	memory := NewMemoryBroker()
+	memory.Start(wsHub.publish)
+	broker = memory
+}
+
+// UseBroker sustituye el broker en memoria (por defecto) por otro, p. ej. PostgresBroker
+func UseBroker(b Broker) error {
+	if err := b.Start(wsHub.publish); err != nil {
+		return err
+	}
+
+	brokerMu.Lock()
+	old := broker
+	broker = b
+	brokerMu.Unlock()
+
+	return old.Close()
+}
+
+func currentBroker() Broker {
+	brokerMu.RLock()
+	defer brokerMu.RUnlock()
+	return broker
+}
+
+// MemoryBroker entrega los eventos solo dentro del proceso. Sirve para una única instancia.
+type MemoryBroker struct {
+	mu      sync.Mutex
+	seq     uint64
+	handler func(Event)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Start(handler func(Event)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handler = handler
+	return nil
+}
+
+// Publish asigna el ID y entrega el evento bajo el mismo lock para que el hub
+// reciba los eventos en orden de secuencia
+func (b *MemoryBroker) Publish(ctx context.Context, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.ID = b.seq
+	if b.handler != nil {
+		b.handler(event)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handler = nil
+	return nil
+}
+
+// publishTimeout limita lo que un handler HTTP puede quedarse esperando al broker
+const publishTimeout = 5 * time.Second
+
+func publishEvent(event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := currentBroker().Publish(ctx, event); err != nil {
+		log.Printf("Error publicando evento %s: %v", event.Type, err)
+	}
+}
@@ -0,0 +1,272 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Canal de LISTEN/NOTIFY compartido por todas las instancias
+	notifyChannel = "realtime_events"
+
+	// Los eventos se guardan en realtime_events porque el payload de NOTIFY está
+	// limitado a 8000 bytes; por el canal solo viaja el ID
+	eventRetention = 24 * time.Hour
+
+	listenRetryDelay = 2 * time.Second
+
+	// Al reconectar se vuelven a leer también los últimos catchUpSlack IDs ya vistos, por
+	// si alguna transacción con un ID menor se confirmó después que otra con uno mayor.
+	// seenWindow IDs recientes se recuerdan para no entregar dos veces el mismo evento.
+	catchUpSlack = 100
+	seenWindow   = 1000
+)
+
+// wireEvent es la representación completa de un evento entre instancias, incluidos
+// los campos que no se envían a los clientes
+type wireEvent struct {
+	Type         EventType       `json:"type"`
+	Version      int             `json:"version"`
+	Timestamp    time.Time       `json:"timestamp"`
+	TournamentID int             `json:"tournament_id,omitempty"`
+	Payload      json.RawMessage `json:"payload"`
+	Topics       []string        `json:"topics"`
+	UserID       int             `json:"user_id,omitempty"`
+	Legacy       string          `json:"legacy,omitempty"`
+}
+
+func encodeWireEvent(event Event) ([]byte, error) {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(wireEvent{
+		Type:         event.Type,
+		Version:      event.Version,
+		Timestamp:    event.Timestamp,
+		TournamentID: event.TournamentID,
+		Payload:      payload,
+		Topics:       event.Topics,
+		UserID:       event.UserID,
+		Legacy:       event.Legacy,
+	})
+}
+
+func decodeWireEvent(id uint64, data []byte) (Event, error) {
+	var w wireEvent
+	if err := json.Unmarshal(data, &w); err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:           id,
+		Type:         w.Type,
+		Version:      w.Version,
+		Timestamp:    w.Timestamp,
+		TournamentID: w.TournamentID,
+		Payload:      w.Payload,
+		Topics:       w.Topics,
+		UserID:       w.UserID,
+		Legacy:       w.Legacy,
+	}, nil
+}
+
+// PostgresBroker reparte los eventos entre instancias con LISTEN/NOTIFY sobre el pool
+// de la aplicación. El ID de secuencia es el de la fila en realtime_events, así que
+// es el mismo en todas las instancias.
+type PostgresBroker struct {
+	pool *pgxpool.Pool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Estado de la goroutine de escucha. Los eventos con ID <= floor ya existían al
+	// arrancar y no se entregan; lastID es el mayor ID entregado.
+	floor  uint64
+	lastID uint64
+	seen   map[uint64]bool
+}
+
+func NewPostgresBroker(pool *pgxpool.Pool) *PostgresBroker {
+	return &PostgresBroker{pool: pool}
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, event Event) error {
+	data, err := encodeWireEvent(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.pool.Exec(ctx, `
+        WITH e AS (
+            INSERT INTO realtime_events (data) VALUES ($1) RETURNING id
+        )
+        SELECT pg_notify('`+notifyChannel+`', id::text) FROM e
+    `, data)
+	return err
+}
+
+func (b *PostgresBroker) Start(handler func(Event)) error {
+	if b.cancel != nil {
+		return errors.New("el broker ya está iniciado")
+	}
+
+	err := b.pool.QueryRow(context.Background(),
+		`SELECT COALESCE(MAX(id), 0) FROM realtime_events`).Scan(&b.floor)
+	if err != nil {
+		return err
+	}
+	b.lastID = b.floor
+	b.seen = make(map[uint64]bool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			err := b.listen(ctx, handler)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Conexión LISTEN perdida, reintentando: %v", err)
+
+			select {
+			case <-time.After(listenRetryDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// listen mantiene una conexión dedicada con LISTEN y entrega cada evento notificado.
+// Tras conectar (también al reconectar) entrega los eventos publicados mientras no
+// había conexión.
+func (b *PostgresBroker) listen(ctx context.Context, handler func(Event)) error {
+	conn, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+
+	if err := b.catchUp(ctx, handler); err != nil {
+		conn.Conn().Close(context.Background())
+		return err
+	}
+
+	lastCleanup := time.Now()
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			// La conexión queda con LISTEN activo; se descarta en lugar de devolverla al pool
+			conn.Conn().Close(context.Background())
+			return err
+		}
+
+		id, err := strconv.ParseUint(notification.Payload, 10, 64)
+		if err != nil || !b.pending(id) {
+			continue
+		}
+
+		var data []byte
+		err = b.pool.QueryRow(ctx, `SELECT data FROM realtime_events WHERE id = $1`, id).Scan(&data)
+		if err != nil {
+			log.Printf("Error leyendo el evento %d: %v", id, err)
+			continue
+		}
+		b.deliver(id, data, handler)
+
+		if time.Since(lastCleanup) > time.Hour {
+			lastCleanup = time.Now()
+			b.cleanup(ctx)
+		}
+	}
+}
+
+// catchUp entrega, en orden, los eventos guardados que aún no se han entregado
+func (b *PostgresBroker) catchUp(ctx context.Context, handler func(Event)) error {
+	from := b.floor
+	if b.lastID > from+catchUpSlack {
+		from = b.lastID - catchUpSlack
+	}
+
+	rows, err := b.pool.Query(ctx, `
+        SELECT id, data FROM realtime_events WHERE id > $1 ORDER BY id
+    `, from)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uint64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		if b.pending(id) {
+			b.deliver(id, data, handler)
+		}
+	}
+	return rows.Err()
+}
+
+// pending indica si el evento es posterior al arranque y aún no se ha entregado
+func (b *PostgresBroker) pending(id uint64) bool {
+	return id > b.floor && !b.seen[id]
+}
+
+func (b *PostgresBroker) deliver(id uint64, data []byte, handler func(Event)) {
+	b.seen[id] = true
+	if id > b.lastID {
+		b.lastID = id
+	}
+	if len(b.seen) > 2*seenWindow {
+		for seenID := range b.seen {
+			if seenID+seenWindow < b.lastID {
+				delete(b.seen, seenID)
+			}
+		}
+	}
+
+	event, err := decodeWireEvent(id, data)
+	if err != nil {
+		log.Printf("Error decodificando el evento %d: %v", id, err)
+		return
+	}
+	handler(event)
+}
+
+// cleanup borra los eventos más antiguos que eventRetention
+func (b *PostgresBroker) cleanup(ctx context.Context) {
+	_, err := b.pool.Exec(ctx, `
+        DELETE FROM realtime_events WHERE created_at < NOW() - $1::interval
+    `, eventRetention.String())
+	if err != nil {
+		log.Printf("Error limpiando realtime_events: %v", err)
+	}
+}
+
+func (b *PostgresBroker) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+		b.wg.Wait()
+	}
+	return nil
+}
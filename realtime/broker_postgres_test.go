@@ -0,0 +1,183 @@
+package realtime
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"torneos/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Las pruebas del broker necesitan una base de datos PostgreSQL de pruebas:
+// TEST_DATABASE_URL=postgres://... go test ./realtime
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL no definida")
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("conectando a la base de datos: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migration, err := os.ReadFile("../migrations/009_create_realtime_events.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(context.Background(), string(migration)); err != nil {
+		t.Fatalf("creando realtime_events: %v", err)
+	}
+	return pool
+}
+
+// startHub arranca un hub con su propio PostgresBroker, como una instancia del backend
+func startHub(t *testing.T, pool *pgxpool.Pool) (*Hub, *PostgresBroker) {
+	t.Helper()
+
+	b := NewPostgresBroker(pool)
+	hub := newHub()
+	hub.broker = func() Broker { return b }
+	go hub.run()
+
+	if err := b.Start(hub.publish); err != nil {
+		t.Fatalf("iniciando el broker: %v", err)
+	}
+
+	t.Cleanup(func() {
+		b.Close()
+		hub.stopOnce.Do(func() { close(hub.done) })
+		<-hub.stopped
+	})
+	return hub, b
+}
+
+func connectTestClient(hub *Hub, lastEventID uint64, topics ...string) *Client {
+	c := &Client{
+		hub:         hub,
+		send:        make(chan Event, sendBufferSize),
+		format:      FormatJSON,
+		replay:      lastEventID > 0,
+		lastEventID: lastEventID,
+		topics:      map[string]bool{},
+	}
+	for _, topic := range topics {
+		c.topics[topic] = true
+	}
+	hub.register <- c
+	return c
+}
+
+// receive espera el siguiente evento del tipo indicado, ignorando los de presencia
+func receive(t *testing.T, c *Client, eventType EventType) Event {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-c.send:
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no se recibió ningún evento %s", eventType)
+		}
+	}
+}
+
+func TestPostgresBrokerDeliversAcrossHubs(t *testing.T) {
+	pool := testPool(t)
+	hubA, brokerA := startHub(t, pool)
+	hubB, _ := startHub(t, pool)
+
+	topic := TournamentTopic(424242)
+	onA := connectTestClient(hubA, 0, topic)
+	onB := connectTestClient(hubB, 0, topic)
+
+	event := NewParticipantJoinedEvent(424242, &models.User{ID: 1, Username: "jugador"})
+	if err := brokerA.Publish(context.Background(), event); err != nil {
+		t.Fatalf("publicando: %v", err)
+	}
+
+	fromA := receive(t, onA, EventParticipantJoined)
+	fromB := receive(t, onB, EventParticipantJoined)
+
+	var storedID uint64
+	err := pool.QueryRow(context.Background(), `SELECT MAX(id) FROM realtime_events`).Scan(&storedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromB.ID == 0 || fromB.ID != fromA.ID || fromB.ID != storedID {
+		t.Fatalf("IDs distintos: hub A %d, hub B %d, realtime_events %d", fromA.ID, fromB.ID, storedID)
+	}
+
+	// Un cliente que reconecta al hub B con last_event_id recibe el evento del historial
+	replayed := connectTestClient(hubB, fromB.ID-1, topic)
+	if got := receive(t, replayed, EventParticipantJoined); got.ID != fromB.ID {
+		t.Fatalf("el replay devolvió el evento %d, se esperaba %d", got.ID, fromB.ID)
+	}
+}
+
+func TestPostgresBrokerCatchesUpAfterReconnect(t *testing.T) {
+	pool := testPool(t)
+	_, brokerA := startHub(t, pool)
+	hubB, _ := startHub(t, pool)
+
+	topic := TournamentTopic(434343)
+	onB := connectTestClient(hubB, 0, topic)
+
+	// Corta las conexiones LISTEN de los brokers: el evento se publica mientras esperan
+	// para reconectar y debe llegar igualmente
+	_, err := pool.Exec(context.Background(), `
+        SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+        WHERE query = 'LISTEN `+notifyChannel+`' AND pid <> pg_backend_pid()
+    `)
+	if err != nil {
+		t.Fatalf("cortando las conexiones LISTEN: %v", err)
+	}
+
+	event := NewParticipantJoinedEvent(434343, &models.User{ID: 1, Username: "jugador"})
+	if err := brokerA.Publish(context.Background(), event); err != nil {
+		t.Fatalf("publicando: %v", err)
+	}
+
+	if got := receive(t, onB, EventParticipantJoined); got.ID == 0 {
+		t.Fatal("el evento llegó sin ID")
+	}
+}
+
+func TestPresenceIsSharedAcrossHubs(t *testing.T) {
+	pool := testPool(t)
+	hubA, _ := startHub(t, pool)
+	hubB, _ := startHub(t, pool)
+
+	topic := TournamentTopic(444444)
+	onB := connectTestClient(hubB, 0, topic)
+
+	hubA.register <- &Client{
+		hub:    hubA,
+		send:   make(chan Event, sendBufferSize),
+		format: FormatJSON,
+		topics: map[string]bool{topic: true},
+		userID: 7,
+	}
+
+	// Los clientes del hub B ven entrar al usuario conectado al hub A
+	for {
+		join := receive(t, onB, EventPresenceJoin)
+		if join.Payload.(PresencePayload).UserID == 7 {
+			break
+		}
+	}
+
+	snapshot := hubB.tournamentPresence(444444)
+	if len(snapshot.UserIDs) != 1 || snapshot.UserIDs[0] != 7 {
+		t.Fatalf("el hub B no ve al usuario del hub A: %+v", snapshot)
+	}
+}
@@ -0,0 +1,294 @@
+package realtime
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Tiempo máximo para escribir un mensaje en el socket
+	writeWait = 10 * time.Second
+
+	// Tiempo máximo sin recibir un pong antes de dar la conexión por muerta
+	pongWait = 60 * time.Second
+
+	// Frecuencia de los pings; debe ser menor que pongWait
+	pingPeriod = (pongWait * 9) / 10
+
+	// Tamaño máximo de los mensajes de control del cliente
+	maxMessageSize = 4096
+
+	// Eventos pendientes por cliente; si se llena, el cliente se desconecta por lento
+	sendBufferSize = 64
+)
+
+// Client es una conexión WebSocket registrada en el hub. Cada cliente tiene su propia
+// cola de envío y una goroutine escritora, así un cliente lento no bloquea al resto.
+type Client struct {
+	hub    *Hub
+	conn   *websocket.Conn
+	send   chan Event
+	format string
+
+	// Reenvío del historial pedido al conectar (?last_event_id=)
+	replay      bool
+	lastEventID uint64
+
+	mu            sync.Mutex
+	topics        map[string]bool
+	userID        int       // 0 si la conexión no está autenticada
+	authExpiresAt time.Time // caducidad del token con el que se autenticó
+
+	// Presencia registrada en el hub; solo la toca la goroutine del hub
+	presence       map[int]bool
+	presenceUserID int
+}
+
+func (c *Client) subscribedTopics() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	topics := make([]string, 0, len(c.topics))
+	for t := range c.topics {
+		topics = append(topics, t)
+	}
+	return topics
+}
+
+func (c *Client) addTopics(topics []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range topics {
+		c.topics[t] = true
+	}
+}
+
+func (c *Client) removeTopics(topics []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range topics {
+		delete(c.topics, t)
+	}
+}
+
+// expireAuth quita la autenticación si el token ha caducado e indica si lo ha hecho
+func (c *Client) expireAuth(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.userID == 0 || now.Before(c.authExpiresAt) {
+		return false
+	}
+	delete(c.topics, UserTopic(c.userID))
+	c.userID = 0
+	c.authExpiresAt = time.Time{}
+	return true
+}
+
+// subscribe pide al hub que añada los topics y reenvíe el historial si hace falta
+func (c *Client) subscribe(topics []string, ack Event, msg controlMessage) {
+	s := subscription{client: c, topics: topics, ack: ack}
+	if msg.LastEventID != nil {
+		s.replay = true
+		s.since = *msg.LastEventID
+	}
+
+	select {
+	case c.hub.subscriptions <- s:
+	case <-c.hub.done:
+	}
+}
+
+// subscribed indica si el cliente debe recibir el evento. Los eventos privados solo
+// llegan al usuario destinatario. Los clientes legacy no gestionan suscripciones y
+// siguen recibiendo todos los eventos públicos.
+func (c *Client) subscribed(event Event) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if event.UserID != 0 {
+		return c.userID == event.UserID
+	}
+	if c.format == FormatLegacy {
+		return true
+	}
+	for _, t := range event.Topics {
+		if c.topics[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// reply envía una respuesta solo a este cliente. Pasa por el hub porque solo el hub
+// puede escribir en (y cerrar) la cola de envío.
+func (c *Client) reply(event Event) {
+	select {
+	case c.hub.replies <- clientEvent{client: c, event: event}:
+	case <-c.hub.done:
+	}
+}
+
+// readPump lee los mensajes de control del cliente y detecta conexiones muertas
+// mediante el deadline de lectura, que se renueva con cada pong.
+func (c *Client) readPump() {
+	defer func() {
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg controlMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.reply(newErrorEvent("Mensaje de control inválido"))
+			continue
+		}
+
+		c.handleControlMessage(msg)
+	}
+}
+
+// writePump envía los eventos de la cola y los pings periódicos. Es la única
+// goroutine que escribe en la conexión.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// El hub ha cerrado la cola (cliente lento o apagado del servidor)
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			message, ok := encodeEvent(event, c.format)
+			if !ok {
+				continue
+			}
+
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// encodeEvent serializa el evento en el formato de la conexión
+func encodeEvent(event Event, format string) ([]byte, bool) {
+	if format == FormatLegacy {
+		if event.Legacy == "" {
+			return nil, false
+		}
+		return []byte(event.Legacy), true
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// controlMessage es un mensaje enviado por el cliente:
+// {"action": "auth", "token": "..."}, {"action": "subscribe", "topics": ["tournament:5"]}
+// o {"action": "unsubscribe", "topic": "global"}. Las acciones auth y subscribe aceptan
+// "last_event_id" para recibir los eventos perdidos de esos topics.
+type controlMessage struct {
+	Action      string   `json:"action"`
+	Token       string   `json:"token"`
+	Topic       string   `json:"topic"`
+	Topics      []string `json:"topics"`
+	LastEventID *uint64  `json:"last_event_id"`
+}
+
+func (m controlMessage) allTopics() []string {
+	topics := m.Topics
+	if m.Topic != "" {
+		topics = append(topics, m.Topic)
+	}
+	return topics
+}
+
+func (c *Client) handleControlMessage(msg controlMessage) {
+	if msg.Action == "auth" {
+		userID, expiresAt, err := authenticateToken(msg.Token)
+		if err != nil {
+			c.reply(newErrorEvent("Token inválido"))
+			return
+		}
+
+		c.mu.Lock()
+		if c.userID != 0 {
+			delete(c.topics, UserTopic(c.userID))
+		}
+		c.userID = userID
+		c.authExpiresAt = expiresAt
+		c.mu.Unlock()
+
+		c.subscribe([]string{UserTopic(userID)}, newAuthenticatedEvent(userID), msg)
+		return
+	}
+
+	c.mu.Lock()
+	ownTopic := ""
+	if c.userID != 0 {
+		ownTopic = UserTopic(c.userID)
+	}
+	c.mu.Unlock()
+
+	topics := msg.allTopics()
+	for _, t := range topics {
+		if !ValidTopic(t) {
+			c.reply(newErrorEvent("Topic inválido: " + t))
+			return
+		}
+		if strings.HasPrefix(t, "user:") && t != ownTopic {
+			c.reply(newErrorEvent("No puedes suscribirte a los eventos de otro usuario"))
+			return
+		}
+	}
+
+	switch msg.Action {
+	case "subscribe":
+		c.subscribe(topics, newSubscriptionEvent(EventSubscribed, topics), msg)
+	case "unsubscribe":
+		select {
+		case c.hub.subscriptions <- subscription{client: c, topics: topics, unsubscribe: true,
+			ack: newSubscriptionEvent(EventUnsubscribed, topics)}:
+		case <-c.hub.done:
+		}
+	default:
+		c.reply(newErrorEvent("Acción desconocida: " + msg.Action))
+	}
+}
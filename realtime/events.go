@@ -0,0 +1,254 @@
+package realtime
+
+import (
+	"fmt"
+	"time"
+
+	"torneos/models"
+)
+
+// EventVersion es la versión actual del esquema de eventos
+const EventVersion = 1
+
+type EventType string
+
+const (
+	EventBracketGenerated    EventType = "bracket_generated"
+	EventMatchResult         EventType = "match_result"
+	EventTournamentFinished  EventType = "tournament_finished"
+	EventAchievementUnlocked EventType = "achievement_unlocked"
+	EventMatchReady          EventType = "match_ready"
+	EventOpponentReported    EventType = "opponent_reported"
+	EventPresenceJoin        EventType = "presence_join"
+	EventPresenceLeave       EventType = "presence_leave"
+	EventNotification        EventType = "notification"
+	EventParticipantJoined   EventType = "participant_joined"
+
+	// Respuestas a los mensajes de control del cliente
+	EventAuthenticated EventType = "authenticated"
+	EventSubscribed    EventType = "subscribed"
+	EventUnsubscribed  EventType = "unsubscribed"
+	EventError         EventType = "error"
+
+	// Fin de la sesión del socket: el token ha caducado (la conexión sigue abierta y
+	// puede volver a autenticarse) o se ha revocado (la conexión se cierra)
+	EventSessionExpired EventType = "session_expired"
+	EventSessionRevoked EventType = "session_revoked"
+)
+
+// Event es el sobre JSON que se envía a los clientes
+type Event struct {
+	// Número de secuencia creciente asignado al publicar; los clientes lo usan para
+	// pedir los eventos perdidos al reconectar (last_event_id)
+	ID           uint64      `json:"id,omitempty"`
+	Type         EventType   `json:"type"`
+	Version      int         `json:"version"`
+	Timestamp    time.Time   `json:"timestamp"`
+	TournamentID int         `json:"tournament_id,omitempty"`
+	Payload      interface{} `json:"payload"`
+
+	// Topics a los que se enruta el evento
+	Topics []string `json:"-"`
+
+	// Destinatario de los eventos privados (0 si el evento es público)
+	UserID int `json:"-"`
+
+	// Mensaje en el formato antiguo EVENT:...|...; solo se envía a los clientes legacy
+	Legacy string `json:"-"`
+}
+
+func newEvent(eventType EventType, tournamentID int, payload interface{}, legacy string, topics ...string) Event {
+	return Event{
+		Type:         eventType,
+		Version:      EventVersion,
+		Timestamp:    time.Now().UTC(),
+		TournamentID: tournamentID,
+		Payload:      payload,
+		Topics:       topics,
+		Legacy:       legacy,
+	}
+}
+
+// Player son los datos públicos de un jugador incluidos en los eventos
+type Player struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type MatchPayload struct {
+	ID            int        `json:"id"`
+	TournamentID  int        `json:"tournament_id"`
+	Round         int        `json:"round"`
+	Status        string     `json:"status"`
+	PlayedAt      *time.Time `json:"played_at"`
+	ScreenshotURL *string    `json:"screenshot_url"`
+	Player1       *Player    `json:"player1"`
+	Player2       *Player    `json:"player2"`
+	Winner        *Player    `json:"winner"`
+}
+
+type BracketGeneratedPayload struct {
+	Matches []MatchPayload `json:"matches"`
+}
+
+type MatchResultPayload struct {
+	Match MatchPayload `json:"match"`
+}
+
+type TournamentFinishedPayload struct {
+	Champion *Player `json:"champion"`
+	RunnerUp *Player `json:"runner_up"`
+}
+
+type OpponentReportedPayload struct {
+	Match      MatchPayload `json:"match"`
+	ReportedBy int          `json:"reported_by"`
+}
+
+type AuthenticatedPayload struct {
+	UserID int `json:"user_id"`
+}
+
+type SubscriptionPayload struct {
+	Topics []string `json:"topics"`
+}
+
+type ErrorPayload struct {
+	Message string `json:"message"`
+}
+
+type ParticipantJoinedPayload struct {
+	Player *Player `json:"player"`
+}
+
+type AchievementUnlockedPayload struct {
+	UserID      int    `json:"user_id"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// PresencePayload indica quién entra o sale de un torneo (user_id vacío si es un
+// espectador sin sesión) y cuántas conexiones lo siguen
+type PresencePayload struct {
+	UserID   int `json:"user_id,omitempty"`
+	Watchers int `json:"watchers"`
+}
+
+func NewPlayer(u *models.User) *Player {
+	if u == nil {
+		return nil
+	}
+	return &Player{ID: u.ID, Username: u.Username, AvatarURL: u.AvatarURL}
+}
+
+func NewMatchPayload(m models.Match) MatchPayload {
+	return MatchPayload{
+		ID:            m.ID,
+		TournamentID:  m.TournamentID,
+		Round:         m.Round,
+		Status:        m.Status,
+		PlayedAt:      m.PlayedAt,
+		ScreenshotURL: m.ScreenshotURL,
+		Player1:       NewPlayer(m.Player1),
+		Player2:       NewPlayer(m.Player2),
+		Winner:        NewPlayer(m.Winner),
+	}
+}
+
+func NewBracketGeneratedEvent(tournamentID int, matches []models.Match) Event {
+	payload := BracketGeneratedPayload{Matches: []MatchPayload{}}
+	for _, m := range matches {
+		payload.Matches = append(payload.Matches, NewMatchPayload(m))
+	}
+
+	return newEvent(EventBracketGenerated, tournamentID, payload,
+		fmt.Sprintf("EVENT:BRACKET|TOURNAMENT:%d|MESSAGE:Bracket generado", tournamentID),
+		TournamentTopic(tournamentID), TopicGlobal)
+}
+
+func NewMatchResultEvent(m models.Match) Event {
+	topics := []string{TournamentTopic(m.TournamentID), MatchTopic(m.ID)}
+	if m.Player1ID != nil {
+		topics = append(topics, UserTopic(*m.Player1ID))
+	}
+	if m.Player2ID != nil {
+		topics = append(topics, UserTopic(*m.Player2ID))
+	}
+
+	return newEvent(EventMatchResult, m.TournamentID, MatchResultPayload{Match: NewMatchPayload(m)},
+		fmt.Sprintf("EVENT:MATCH_RESULT|MATCH:%d|TOURNAMENT:%d|MESSAGE:Resultado reportado", m.ID, m.TournamentID),
+		topics...)
+}
+
+func NewTournamentFinishedEvent(tournamentID int, champion, runnerUp *models.User) Event {
+	championID := 0
+	if champion != nil {
+		championID = champion.ID
+	}
+
+	return newEvent(EventTournamentFinished, tournamentID,
+		TournamentFinishedPayload{Champion: NewPlayer(champion), RunnerUp: NewPlayer(runnerUp)},
+		fmt.Sprintf("EVENT:WINNER|TOURNAMENT:%d|WINNER_ID:%d|MESSAGE:Torneo finalizado", tournamentID, championID),
+		TournamentTopic(tournamentID), TopicGlobal)
+}
+
+func NewParticipantJoinedEvent(tournamentID int, user *models.User) Event {
+	return newEvent(EventParticipantJoined, tournamentID, ParticipantJoinedPayload{Player: NewPlayer(user)}, "",
+		TournamentTopic(tournamentID))
+}
+
+func NewAchievementUnlockedEvent(userID int, tournamentID *int, code, name, description string) Event {
+	id := 0
+	if tournamentID != nil {
+		id = *tournamentID
+	}
+
+	return newEvent(EventAchievementUnlocked, id,
+		AchievementUnlockedPayload{UserID: userID, Code: code, Name: name, Description: description},
+		fmt.Sprintf("EVENT:ACHIEVEMENT|USER:%d|ACHIEVEMENT:%s|MESSAGE:Logro desbloqueado", userID, code),
+		UserTopic(userID))
+}
+
+// NewMatchReadyEvent avisa a un jugador de que su match ya tiene rival
+func NewMatchReadyEvent(m models.Match) Event {
+	return newEvent(EventMatchReady, m.TournamentID, MatchResultPayload{Match: NewMatchPayload(m)}, "")
+}
+
+// NewOpponentReportedEvent avisa a un jugador de que su rival ha reportado el resultado
+func NewOpponentReportedEvent(m models.Match, reportedBy int) Event {
+	return newEvent(EventOpponentReported, m.TournamentID,
+		OpponentReportedPayload{Match: NewMatchPayload(m), ReportedBy: reportedBy}, "")
+}
+
+// NewNotificationEvent entrega al usuario una notificación recién guardada
+func NewNotificationEvent(n models.Notification) Event {
+	tournamentID := 0
+	if n.TournamentID != nil {
+		tournamentID = *n.TournamentID
+	}
+	return newEvent(EventNotification, tournamentID, n, "")
+}
+
+// NewPresenceEvent crea un evento presence_join o presence_leave para el topic del torneo
+func NewPresenceEvent(eventType EventType, tournamentID, userID, watchers int) Event {
+	return newEvent(eventType, tournamentID, PresencePayload{UserID: userID, Watchers: watchers}, "",
+		TournamentTopic(tournamentID))
+}
+
+func newAuthenticatedEvent(userID int) Event {
+	return newEvent(EventAuthenticated, 0, AuthenticatedPayload{UserID: userID}, "")
+}
+
+func newSubscriptionEvent(eventType EventType, topics []string) Event {
+	return newEvent(eventType, 0, SubscriptionPayload{Topics: topics}, "")
+}
+
+func newSessionExpiredEvent() Event {
+	return newEvent(EventSessionExpired, 0, nil, "")
+}
+
+func newErrorEvent(message string) Event {
+	return newEvent(EventError, 0, ErrorPayload{Message: message}, "")
+}
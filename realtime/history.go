@@ -0,0 +1,97 @@
+package realtime
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Eventos guardados por topic para reenviarlos en una reconexión
+	historyPerTopic = 100
+
+	// Los topics sin eventos nuevos durante este tiempo se descartan
+	historyTTL = time.Hour
+)
+
+type topicHistory struct {
+	events  []Event
+	updated time.Time
+}
+
+// eventHistory guarda en memoria los últimos eventos de cada topic
+type eventHistory struct {
+	mu     sync.Mutex
+	topics map[string]*topicHistory
+}
+
+func newEventHistory() *eventHistory {
+	return &eventHistory{topics: make(map[string]*topicHistory)}
+}
+
+func (h *eventHistory) add(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range event.Topics {
+		th, ok := h.topics[topic]
+		if !ok {
+			th = &topicHistory{}
+			h.topics[topic] = th
+		}
+
+		th.events = append(th.events, event)
+		if len(th.events) > historyPerTopic {
+			th.events = th.events[len(th.events)-historyPerTopic:]
+		}
+		th.updated = time.Now()
+	}
+}
+
+// since devuelve, ordenados y sin duplicados, los eventos de los topics con ID mayor que lastID
+func (h *eventHistory) since(topics []string, lastID uint64) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := map[uint64]bool{}
+	var events []Event
+	for _, topic := range topics {
+		th, ok := h.topics[topic]
+		if !ok {
+			continue
+		}
+		for _, e := range th.events {
+			if e.ID > lastID && !seen[e.ID] {
+				seen[e.ID] = true
+				events = append(events, e)
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+// prune descarta los topics que llevan más de historyTTL sin eventos
+func (h *eventHistory) prune() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic, th := range h.topics {
+		if time.Since(th.updated) > historyTTL {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// EventsSince devuelve los eventos públicos de un topic posteriores a lastID,
+// para los clientes que consultan por polling en lugar de mantener un socket.
+func EventsSince(topic string, lastID uint64) []Event {
+	events := []Event{}
+	for _, e := range wsHub.history.since([]string{topic}, lastID) {
+		if e.UserID == 0 {
+			events = append(events, e)
+		}
+	}
+	return events
+}
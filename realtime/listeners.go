@@ -0,0 +1,33 @@
+package realtime
+
+import "sync"
+
+// Listener recibe los eventos públicos publicados desde esta instancia, p. ej. para
+// enviarlos a webhooks externos. Se ejecuta en la goroutine que llama a Broadcast,
+// así que no debe bloquear.
+type Listener func(Event)
+
+var (
+	listenersMu sync.RWMutex
+	listeners   []Listener
+)
+
+func AddListener(l Listener) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, l)
+}
+
+// notifyListeners solo se llama en la instancia que publica el evento, no en las que
+// lo reciben del broker, para que cada evento se procese una única vez
+func notifyListeners(event Event) {
+	if event.UserID != 0 {
+		return
+	}
+
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, l := range listeners {
+		l(event)
+	}
+}
@@ -0,0 +1,521 @@
+package realtime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Tiempo que se sigue considerando presente a un usuario tras cerrar su última
+	// conexión, para que una recarga de página o una reconexión no generen leave/join
+	presenceGrace = 10 * time.Second
+
+	// Cada instancia publica su presencia en el broker como mucho una vez por
+	// presenceFlushInterval si ha cambiado, y al menos cada presenceHeartbeat aunque
+	// no cambie. La presencia de una instancia que deja de publicar (caída o apagada)
+	// se descarta pasado presenceInstanceTTL.
+	presenceFlushInterval = time.Second
+	presenceHeartbeat     = 15 * time.Second
+	presenceInstanceTTL   = 45 * time.Second
+)
+
+// eventPresenceSync es un evento interno entre instancias: no se guarda en el
+// historial ni se entrega a los clientes
+const eventPresenceSync EventType = "presence_sync"
+
+type presenceKey struct {
+	tournamentID int
+	userID       int
+}
+
+// presenceSync es la presencia de los clientes conectados a una instancia, tal como
+// se publica en el broker para que las demás la sumen a la suya
+type presenceSync struct {
+	Instance  string        `json:"instance"`
+	Watchers  map[int]int   `json:"watchers"`
+	Anonymous map[int]int   `json:"anonymous"`
+	Users     map[int][]int `json:"users"` // incluidos los que están en periodo de gracia
+	Online    []int         `json:"online"`
+}
+
+// remotePresence es la última presencia recibida de otra instancia
+type remotePresence struct {
+	watchers  map[int]int
+	anonymous map[int]int
+	users     map[int]map[int]bool
+	online    map[int]bool
+	updated   time.Time
+}
+
+// tournamentPresence es la presencia de un torneo sumando todas las instancias
+type tournamentPresence struct {
+	watchers  int
+	anonymous int
+	users     map[int]bool
+}
+
+// presenceTracker cuenta las conexiones suscritas a cada torneo. Solo lo modifica
+// la goroutine del hub; el mutex protege las lecturas desde los handlers HTTP.
+// Los contadores locales son los de los clientes de esta instancia; la presencia
+// del resto del cluster llega por el broker y se guarda en remote.
+type presenceTracker struct {
+	mu        sync.RWMutex
+	watchers  map[int]int         // conexiones por torneo, autenticadas o no
+	anonymous map[int]int         // conexiones sin sesión por torneo
+	users     map[int]map[int]int // conexiones autenticadas por torneo y usuario
+	online    map[int]int         // conexiones autenticadas por usuario
+	leaving   map[presenceKey]*time.Timer
+
+	remote map[string]*remotePresence
+
+	// Presencia de cada torneo tal como se anunció por última vez a los clientes
+	announced map[int]tournamentPresence
+
+	dirty     bool
+	published time.Time
+}
+
+func newPresenceTracker() *presenceTracker {
+	return &presenceTracker{
+		watchers:  make(map[int]int),
+		anonymous: make(map[int]int),
+		users:     make(map[int]map[int]int),
+		online:    make(map[int]int),
+		leaving:   make(map[presenceKey]*time.Timer),
+		remote:    make(map[string]*remotePresence),
+		announced: make(map[int]tournamentPresence),
+	}
+}
+
+func newInstanceID() string {
+	return rand.Text()
+}
+
+// presenceTournaments devuelve los torneos a los que está suscrito el cliente
+func (c *Client) presenceTournaments() map[int]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tournaments := map[int]bool{}
+	for t := range c.topics {
+		if id, ok := strings.CutPrefix(t, "tournament:"); ok {
+			if n, err := strconv.Atoi(id); err == nil {
+				tournaments[n] = true
+			}
+		}
+	}
+	return tournaments
+}
+
+func (c *Client) currentUserID() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.userID
+}
+
+// updatePresence compara la presencia registrada del cliente con sus suscripciones
+// actuales y aplica la diferencia. Con connected=false retira toda su presencia.
+func (h *Hub) updatePresence(client *Client, connected bool) {
+	tournaments := map[int]bool{}
+	userID := 0
+	if connected {
+		tournaments = client.presenceTournaments()
+		userID = client.currentUserID()
+	}
+
+	oldUserID := client.presenceUserID
+	for t := range client.presence {
+		if !tournaments[t] || userID != oldUserID {
+			h.presenceLeave(t, oldUserID)
+		}
+	}
+	for t := range tournaments {
+		if !client.presence[t] || userID != oldUserID {
+			h.presenceJoin(t, userID)
+		}
+	}
+
+	if userID != oldUserID {
+		h.presence.mu.Lock()
+		if oldUserID != 0 {
+			decrement(h.presence.online, oldUserID)
+		}
+		if userID != 0 {
+			h.presence.online[userID]++
+		}
+		h.presence.dirty = true
+		h.presence.mu.Unlock()
+	}
+
+	client.presence = tournaments
+	client.presenceUserID = userID
+}
+
+func decrement(counts map[int]int, key int) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+func (h *Hub) presenceJoin(tournamentID, userID int) {
+	p := h.presence
+	key := presenceKey{tournamentID, userID}
+
+	p.mu.Lock()
+	p.watchers[tournamentID]++
+	if userID == 0 {
+		p.anonymous[tournamentID]++
+	} else {
+		if p.users[tournamentID] == nil {
+			p.users[tournamentID] = make(map[int]int)
+		}
+		p.users[tournamentID][userID]++
+		if timer, ok := p.leaving[key]; ok {
+			// Ha vuelto dentro del periodo de gracia: para los demás nunca se fue
+			timer.Stop()
+			delete(p.leaving, key)
+		}
+	}
+	p.dirty = true
+	p.mu.Unlock()
+
+	h.refreshPresence(tournamentID)
+}
+
+func (h *Hub) presenceLeave(tournamentID, userID int) {
+	p := h.presence
+	key := presenceKey{tournamentID, userID}
+
+	p.mu.Lock()
+	decrement(p.watchers, tournamentID)
+	if userID == 0 {
+		decrement(p.anonymous, tournamentID)
+	} else if users := p.users[tournamentID]; users != nil {
+		decrement(users, userID)
+		if len(users) == 0 {
+			delete(p.users, tournamentID)
+		}
+		if users[userID] == 0 {
+			p.leaving[key] = time.AfterFunc(presenceGrace, func() {
+				select {
+				case h.presenceExpired <- key:
+				case <-h.done:
+				}
+			})
+		}
+	}
+	p.dirty = true
+	p.mu.Unlock()
+
+	h.refreshPresence(tournamentID)
+}
+
+// expirePresence retira a un usuario cuyo periodo de gracia ha terminado
+func (h *Hub) expirePresence(key presenceKey) {
+	p := h.presence
+
+	p.mu.Lock()
+	if _, ok := p.leaving[key]; !ok || p.users[key.tournamentID][key.userID] > 0 {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.leaving, key)
+	p.dirty = true
+	p.mu.Unlock()
+
+	h.refreshPresence(key.tournamentID)
+}
+
+// tournament suma la presencia local y la de las demás instancias. Requiere p.mu.
+func (p *presenceTracker) tournament(tournamentID int) tournamentPresence {
+	current := tournamentPresence{
+		watchers:  p.watchers[tournamentID],
+		anonymous: p.anonymous[tournamentID],
+		users:     map[int]bool{},
+	}
+	for userID := range p.users[tournamentID] {
+		current.users[userID] = true
+	}
+	for key := range p.leaving {
+		if key.tournamentID == tournamentID {
+			current.users[key.userID] = true
+		}
+	}
+	for _, r := range p.remote {
+		current.watchers += r.watchers[tournamentID]
+		current.anonymous += r.anonymous[tournamentID]
+		for userID := range r.users[tournamentID] {
+			current.users[userID] = true
+		}
+	}
+	return current
+}
+
+// refreshPresence compara la presencia de los torneos indicados con la última
+// anunciada y entrega a los clientes de esta instancia los join/leave de la
+// diferencia. Un usuario conectado a varias instancias solo entra y sale una vez.
+func (h *Hub) refreshPresence(tournamentIDs ...int) {
+	p := h.presence
+	var events []Event
+
+	p.mu.Lock()
+	for _, t := range tournamentIDs {
+		current := p.tournament(t)
+		previous := p.announced[t]
+
+		for userID := range current.users {
+			if !previous.users[userID] {
+				events = append(events, NewPresenceEvent(EventPresenceJoin, t, userID, current.watchers))
+			}
+		}
+		for userID := range previous.users {
+			if !current.users[userID] {
+				events = append(events, NewPresenceEvent(EventPresenceLeave, t, userID, current.watchers))
+			}
+		}
+		if current.anonymous > previous.anonymous {
+			events = append(events, NewPresenceEvent(EventPresenceJoin, t, 0, current.watchers))
+		} else if current.anonymous < previous.anonymous {
+			events = append(events, NewPresenceEvent(EventPresenceLeave, t, 0, current.watchers))
+		}
+
+		if current.watchers == 0 && len(current.users) == 0 {
+			delete(p.announced, t)
+		} else {
+			p.announced[t] = current
+		}
+	}
+	p.mu.Unlock()
+
+	for _, event := range events {
+		h.deliverPresence(event)
+	}
+}
+
+// deliverPresence entrega el evento a los clientes de esta instancia sin pasar por el
+// broker ni guardarlo en el historial: la presencia no tiene sentido al reconectar
+func (h *Hub) deliverPresence(event Event) {
+	for client := range h.clients {
+		if client.format != FormatLegacy && client.subscribed(event) {
+			h.deliver(client, event)
+		}
+	}
+}
+
+// localPresence devuelve la presencia de los clientes de esta instancia para publicarla
+func (h *Hub) localPresence() presenceSync {
+	p := h.presence
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	local := presenceSync{
+		Instance:  h.instanceID,
+		Watchers:  make(map[int]int, len(p.watchers)),
+		Anonymous: make(map[int]int, len(p.anonymous)),
+		Users:     make(map[int][]int),
+		Online:    make([]int, 0, len(p.online)),
+	}
+	for t, n := range p.watchers {
+		local.Watchers[t] = n
+	}
+	for t, n := range p.anonymous {
+		local.Anonymous[t] = n
+	}
+	for t, users := range p.users {
+		for userID := range users {
+			local.Users[t] = append(local.Users[t], userID)
+		}
+	}
+	for key := range p.leaving {
+		local.Users[key.tournamentID] = append(local.Users[key.tournamentID], key.userID)
+	}
+	for userID := range p.online {
+		local.Online = append(local.Online, userID)
+	}
+	return local
+}
+
+// syncPresence descarta las instancias que han dejado de publicar y, si la presencia
+// local ha cambiado o toca el heartbeat, la encola para publicarla
+func (h *Hub) syncPresence() {
+	p := h.presence
+	var expired []int
+
+	p.mu.Lock()
+	for instance, r := range p.remote {
+		if time.Since(r.updated) > presenceInstanceTTL {
+			delete(p.remote, instance)
+			expired = append(expired, r.tournaments()...)
+		}
+	}
+	due := p.dirty || time.Since(p.published) >= presenceHeartbeat
+	if due {
+		p.dirty = false
+		p.published = time.Now()
+	}
+	p.mu.Unlock()
+
+	if len(expired) > 0 {
+		h.refreshPresence(expired...)
+	}
+	if due && h.broker != nil {
+		event := newEvent(eventPresenceSync, 0, h.localPresence(), "")
+		// Solo interesa la última: si la anterior sigue en cola se sustituye
+		select {
+		case <-h.presenceOut:
+		default:
+		}
+		h.presenceOut <- event
+	}
+}
+
+// publishPresence publica en el broker la presencia local. Corre en su propia
+// goroutine para que el hub no espere a la base de datos ni al propio hub (el broker
+// en memoria entrega de forma síncrona).
+func (h *Hub) publishPresence() {
+	for {
+		select {
+		case event := <-h.presenceOut:
+			b := h.broker()
+			if _, local := b.(*MemoryBroker); local {
+				// Con el broker en memoria solo hay una instancia
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			if err := b.Publish(ctx, event); err != nil {
+				log.Printf("Error publicando la presencia: %v", err)
+			}
+			cancel()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// applyPresenceSync guarda la presencia publicada por otra instancia y anuncia a los
+// clientes locales los cambios que produce
+func (h *Hub) applyPresenceSync(event Event) {
+	var remote presenceSync
+	switch payload := event.Payload.(type) {
+	case presenceSync:
+		remote = payload
+	case json.RawMessage:
+		if err := json.Unmarshal(payload, &remote); err != nil {
+			log.Printf("Error decodificando la presencia de otra instancia: %v", err)
+			return
+		}
+	default:
+		return
+	}
+
+	// Las propias vuelven por el broker; las antiguas pueden llegar al recuperar
+	// eventos tras reconectar
+	if remote.Instance == h.instanceID || time.Since(event.Timestamp) > presenceInstanceTTL {
+		return
+	}
+
+	r := &remotePresence{
+		watchers:  remote.Watchers,
+		anonymous: remote.Anonymous,
+		users:     make(map[int]map[int]bool, len(remote.Users)),
+		online:    make(map[int]bool, len(remote.Online)),
+		updated:   event.Timestamp,
+	}
+	for t, userIDs := range remote.Users {
+		r.users[t] = make(map[int]bool, len(userIDs))
+		for _, userID := range userIDs {
+			r.users[t][userID] = true
+		}
+	}
+	for _, userID := range remote.Online {
+		r.online[userID] = true
+	}
+
+	p := h.presence
+	p.mu.Lock()
+	old := p.remote[remote.Instance]
+	if old != nil && old.updated.After(r.updated) {
+		p.mu.Unlock()
+		return
+	}
+	p.remote[remote.Instance] = r
+	p.mu.Unlock()
+
+	affected := r.tournaments()
+	if old != nil {
+		affected = append(affected, old.tournaments()...)
+	}
+	h.refreshPresence(affected...)
+}
+
+// tournaments devuelve los torneos en los que la instancia tiene alguna presencia
+func (r *remotePresence) tournaments() []int {
+	var ids []int
+	for t := range r.watchers {
+		ids = append(ids, t)
+	}
+	for t := range r.users {
+		if _, ok := r.watchers[t]; !ok {
+			ids = append(ids, t)
+		}
+	}
+	return ids
+}
+
+// PresenceSnapshot es la presencia actual en un torneo
+type PresenceSnapshot struct {
+	TournamentID int   `json:"tournament_id"`
+	Watchers     int   `json:"watchers"`
+	UserIDs      []int `json:"user_ids"`
+}
+
+// TournamentPresence devuelve cuántas conexiones siguen el torneo y qué usuarios
+// autenticados lo están viendo (incluidos los que están dentro del periodo de gracia)
+// en todas las instancias
+func TournamentPresence(tournamentID int) PresenceSnapshot {
+	return wsHub.tournamentPresence(tournamentID)
+}
+
+func (h *Hub) tournamentPresence(tournamentID int) PresenceSnapshot {
+	p := h.presence
+	p.mu.RLock()
+	current := p.tournament(tournamentID)
+	p.mu.RUnlock()
+
+	snapshot := PresenceSnapshot{
+		TournamentID: tournamentID,
+		Watchers:     current.watchers,
+		UserIDs:      []int{},
+	}
+	for userID := range current.users {
+		snapshot.UserIDs = append(snapshot.UserIDs, userID)
+	}
+	sort.Ints(snapshot.UserIDs)
+	return snapshot
+}
+
+// UserOnline indica si el usuario tiene alguna conexión autenticada abierta en
+// cualquier instancia
+func UserOnline(userID int) bool {
+	p := wsHub.presence
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.online[userID] > 0 {
+		return true
+	}
+	for _, r := range p.remote {
+		if r.online[userID] {
+			return true
+		}
+	}
+	return false
+}
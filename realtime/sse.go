@@ -0,0 +1,90 @@
+package realtime
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// Frecuencia de los comentarios de heartbeat, para que proxies y navegadores no
+// cierren el stream por inactividad
+const sseHeartbeatPeriod = 15 * time.Second
+
+// ServeSSE sirve por Server-Sent Events los eventos públicos de los topics indicados.
+// Usa el mismo hub que /ws y admite reanudar con la cabecera Last-Event-ID
+// (o ?last_event_id= para clientes que no pueden enviar cabeceras).
+func ServeSSE(c *gin.Context, topics ...string) {
+	client := &Client{
+		hub:    wsHub,
+		send:   make(chan Event, sendBufferSize),
+		format: FormatJSON,
+		topics: map[string]bool{},
+	}
+	for _, t := range topics {
+		client.topics[t] = true
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Last-Event-ID inválido"})
+			return
+		}
+		client.replay = true
+		client.lastEventID = id
+	}
+
+	select {
+	case wsHub.register <- client:
+	case <-wsHub.done:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Servidor apagándose"})
+		return
+	}
+	defer func() {
+		select {
+		case wsHub.unregister <- client:
+		case <-wsHub.done:
+		}
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatPeriod)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-client.send:
+			if !ok {
+				// Cliente lento o apagado del servidor
+				return
+			}
+
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(event.ID, 10),
+				Event: string(event.Type),
+				Data:  event,
+			})
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package realtime
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// TopicGlobal recibe los eventos de interés general (brackets generados, torneos finalizados)
+const TopicGlobal = "global"
+
+var topicPattern = regexp.MustCompile(`^(global|(tournament|match|user):[0-9]+)$`)
+
+func TournamentTopic(id int) string { return fmt.Sprintf("tournament:%d", id) }
+
+func MatchTopic(id int) string { return fmt.Sprintf("match:%d", id) }
+
+func UserTopic(id int) string { return fmt.Sprintf("user:%d", id) }
+
+// ValidTopic comprueba que el topic tenga el formato global, tournament:{id}, match:{id} o user:{id}
+func ValidTopic(topic string) bool {
+	return topicPattern.MatchString(topic)
+}
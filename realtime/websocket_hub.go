@@ -1,8 +1,14 @@
 package realtime
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 
@@ -10,57 +16,372 @@ import (
 )
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // permitir cualquier origen (para pruebas; en prod mejor restringir)
-	},
+	CheckOrigin: checkOrigin,
 }
 
-type WebSocketHub struct {
-	clients map[*websocket.Conn]bool
-	mu      sync.Mutex
+// Authenticator valida un token de acceso y devuelve el ID del usuario y cuándo caduca
+type Authenticator func(token string) (userID int, expiresAt time.Time, err error)
+
+// Frecuencia con la que el hub desautentica las conexiones cuyo token ha caducado
+const sessionCheckInterval = 30 * time.Second
+
+var (
+	authenticate   Authenticator
+	allowedOrigins = map[string]bool{}
+)
+
+// Configure establece cómo se validan los tokens de los sockets y qué orígenes
+// pueden abrir conexiones (los mismos que acepta CORS).
+func Configure(authFn Authenticator, origins ...string) {
+	authenticate = authFn
+	allowedOrigins = map[string]bool{}
+	for _, o := range origins {
+		allowedOrigins[strings.TrimSuffix(o, "/")] = true
+	}
 }
 
-var wsHub = WebSocketHub{
-	clients: make(map[*websocket.Conn]bool),
+// checkOrigin acepta los orígenes configurados. Las peticiones sin cabecera Origin
+// no vienen de un navegador (scripts, overlays) y se aceptan.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return allowedOrigins[strings.TrimSuffix(origin, "/")]
 }
 
-// Handler para /ws
-func WebSocketHandler(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		return
+// Formatos de mensaje soportados por conexión
+const (
+	FormatJSON   = "json"
+	FormatLegacy = "legacy"
+)
+
+// Hub mantiene los clientes conectados. Todo el estado del hub se gestiona desde una
+// única goroutine (run) que recibe altas, bajas y eventos por canales.
+type Hub struct {
+	clients       map[*Client]bool
+	register      chan *Client
+	unregister    chan *Client
+	broadcast     chan Event
+	replies       chan clientEvent
+	subscriptions chan subscription
+
+	history *eventHistory
+
+	presence        *presenceTracker
+	presenceExpired chan presenceKey
+
+	// Con broker, la presencia local se publica para el resto del cluster
+	instanceID  string
+	broker      func() Broker
+	presenceOut chan Event
+
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// clientEvent es un evento dirigido a un único cliente (respuestas a mensajes de control)
+type clientEvent struct {
+	client *Client
+	event  Event
+}
+
+// subscription añade (o con unsubscribe, quita) topics a un cliente y, si se indica,
+// le reenvía los eventos del historial posteriores a since. Se procesa en el hub para
+// que no se cuele ningún evento entre la suscripción y la repetición del historial, y
+// para que la presencia se actualice en orden.
+type subscription struct {
+	client      *Client
+	topics      []string
+	unsubscribe bool
+	ack         Event
+	replay      bool
+	since       uint64
+}
+
+func newHub() *Hub {
+	return &Hub{
+		clients:    make(map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan Event, 256),
+		replies:    make(chan clientEvent, 64),
+
+		subscriptions:   make(chan subscription, 64),
+		history:         newEventHistory(),
+		presence:        newPresenceTracker(),
+		presenceExpired: make(chan presenceKey, 64),
+		instanceID:      newInstanceID(),
+		presenceOut:     make(chan Event, 1),
+		done:            make(chan struct{}),
+		stopped:         make(chan struct{}),
 	}
+}
 
-	// Añadir cliente al hub
-	wsHub.mu.Lock()
-	wsHub.clients[conn] = true
-	wsHub.mu.Unlock()
+var wsHub = newHub()
 
-	defer func() {
-		wsHub.mu.Lock()
-		delete(wsHub.clients, conn)
-		wsHub.mu.Unlock()
-		conn.Close()
-	}()
+func init() {
+	wsHub.broker = currentBroker
+	go wsHub.run()
+}
+
+func (h *Hub) run() {
+	defer close(h.stopped)
+
+	pruneTicker := time.NewTicker(historyTTL / 4)
+	defer pruneTicker.Stop()
+
+	presenceTicker := time.NewTicker(presenceFlushInterval)
+	defer presenceTicker.Stop()
+
+	sessionTicker := time.NewTicker(sessionCheckInterval)
+	defer sessionTicker.Stop()
+
+	if h.broker != nil {
+		go h.publishPresence()
+	}
 
-	// Leer mensajes (aunque no esperamos recibir, mantenemos la conexión viva)
 	for {
-		if _, _, err := conn.ReadMessage(); err != nil {
-			break
+		select {
+		case client := <-h.register:
+			h.clients[client] = true
+			h.updatePresence(client, true)
+			if client.replay {
+				h.replay(client, client.subscribedTopics(), client.lastEventID)
+			}
+		case client := <-h.unregister:
+			h.remove(client)
+		case s := <-h.subscriptions:
+			if !h.clients[s.client] {
+				continue
+			}
+			if s.unsubscribe {
+				s.client.removeTopics(s.topics)
+			} else {
+				s.client.addTopics(s.topics)
+			}
+			h.updatePresence(s.client, true)
+			h.deliver(s.client, s.ack)
+			if s.replay {
+				h.replay(s.client, s.topics, s.since)
+			}
+		case key := <-h.presenceExpired:
+			h.expirePresence(key)
+		case <-presenceTicker.C:
+			h.syncPresence()
+		case <-pruneTicker.C:
+			h.history.prune()
+		case <-sessionTicker.C:
+			h.expireSessions(time.Now())
+		case event := <-h.broadcast:
+			if event.Type == eventPresenceSync {
+				h.applyPresenceSync(event)
+				continue
+			}
+			if event.Type == EventSessionRevoked {
+				h.revokeSessions(event)
+				continue
+			}
+			h.history.add(event)
+
+			for client := range h.clients {
+				if client.subscribed(event) {
+					h.deliver(client, event)
+				}
+			}
+		case r := <-h.replies:
+			if h.clients[r.client] {
+				h.deliver(r.client, r.event)
+			}
+		case <-h.done:
+			for client := range h.clients {
+				h.remove(client)
+			}
+			return
+		}
+	}
+}
+
+// expireSessions desautentica las conexiones cuyo token ha caducado: dejan de recibir
+// los eventos privados hasta que el cliente envíe un token nuevo con la acción auth
+func (h *Hub) expireSessions(now time.Time) {
+	for client := range h.clients {
+		if !client.expireAuth(now) {
+			continue
+		}
+		h.updatePresence(client, true)
+		h.deliver(client, newSessionExpiredEvent())
+	}
+}
+
+// revokeSessions avisa y desconecta a las conexiones del usuario del evento
+func (h *Hub) revokeSessions(event Event) {
+	for client := range h.clients {
+		if client.currentUserID() == event.UserID {
+			h.deliver(client, event)
+			h.remove(client)
+		}
+	}
+}
+
+// replay reenvía al cliente los eventos del historial que se perdió
+func (h *Hub) replay(client *Client, topics []string, since uint64) {
+	for _, event := range h.history.since(topics, since) {
+		if !h.clients[client] {
+			return
+		}
+		if client.subscribed(event) {
+			h.deliver(client, event)
 		}
 	}
 }
 
-// Función para enviar broadcast a todos los clientes
-func Broadcast(message string) {
-	wsHub.mu.Lock()
-	defer wsHub.mu.Unlock()
+// deliver encola el evento sin bloquear; si la cola está llena el cliente no consume
+// lo bastante rápido y se desconecta
+func (h *Hub) deliver(client *Client, event Event) {
+	select {
+	case client.send <- event:
+	default:
+		h.remove(client)
+	}
+}
+
+// remove da de baja al cliente y cierra su cola; su writePump cierra la conexión
+func (h *Hub) remove(client *Client) {
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+		h.updatePresence(client, false)
+	}
+}
+
+// publish encola para el hub un evento recibido del broker (ya con ID) sin bloquear
+// al llamante más allá del buffer
+func (h *Hub) publish(event Event) {
+	select {
+	case h.broadcast <- event:
+	case <-h.done:
+	}
+}
+
+// Shutdown deja de recibir eventos del broker, desconecta a todos los clientes y
+// detiene el hub. Se llama al apagar el servidor.
+func Shutdown() {
+	if err := currentBroker().Close(); err != nil {
+		log.Printf("Error cerrando el broker de eventos: %v", err)
+	}
+	wsHub.stopOnce.Do(func() {
+		close(wsHub.done)
+	})
+	<-wsHub.stopped
+}
+
+// clientFormat decide el formato de la conexión: ?format=legacy|json, o WS_EVENT_FORMAT
+// por defecto. El formato legacy se mantiene durante la transición al esquema JSON.
+func clientFormat(r *http.Request) string {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = os.Getenv("WS_EVENT_FORMAT")
+	}
+	if format == FormatLegacy {
+		return FormatLegacy
+	}
+	return FormatJSON
+}
+
+// initialTopics devuelve los topics de ?topics=a,b o "global" si no se indica ninguno
+func initialTopics(r *http.Request) map[string]bool {
+	topics := map[string]bool{}
+	for _, t := range strings.Split(r.URL.Query().Get("topics"), ",") {
+		t = strings.TrimSpace(t)
+		if ValidTopic(t) && !strings.HasPrefix(t, "user:") {
+			topics[t] = true
+		}
+	}
+	if len(topics) == 0 {
+		topics[TopicGlobal] = true
+	}
+	return topics
+}
+
+// authenticateToken valida el token con el Authenticator configurado
+func authenticateToken(token string) (int, time.Time, error) {
+	if authenticate == nil {
+		return 0, time.Time{}, errors.New("autenticación de sockets no configurada")
+	}
+	return authenticate(token)
+}
 
-	for client := range wsHub.clients {
-		err := client.WriteMessage(websocket.TextMessage, []byte(message))
+// Handler para /ws. El token puede enviarse al conectar (?token=) o en el primer
+// mensaje ({"action": "auth", "token": "..."}). Sin token solo se reciben eventos públicos.
+// Con ?last_event_id=N se reenvían los eventos posteriores a N de los topics iniciales.
+func WebSocketHandler(c *gin.Context) {
+	client := &Client{
+		hub:    wsHub,
+		send:   make(chan Event, sendBufferSize),
+		format: clientFormat(c.Request),
+		topics: initialTopics(c.Request),
+	}
+
+	if v := c.Query("last_event_id"); v != "" {
+		lastID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "last_event_id inválido"})
+			return
+		}
+		client.replay = true
+		client.lastEventID = lastID
+	}
+
+	if token := c.Query("token"); token != "" {
+		userID, expiresAt, err := authenticateToken(token)
 		if err != nil {
-			client.Close()
-			delete(wsHub.clients, client)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+			return
 		}
+		client.userID = userID
+		client.authExpiresAt = expiresAt
+		client.topics[UserTopic(userID)] = true
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
 	}
+	client.conn = conn
+
+	select {
+	case wsHub.register <- client:
+	case <-wsHub.done:
+		conn.Close()
+		return
+	}
+
+	go client.writePump()
+	client.readPump()
+}
+
+// SendToUser envía un evento privado solo a las conexiones del usuario indicado
+func SendToUser(userID int, event Event) {
+	event.UserID = userID
+	event.Topics = []string{UserTopic(userID)}
+	Broadcast(event)
+}
+
+// DisconnectUser cierra las conexiones del usuario en todas las instancias. Se llama
+// cuando sus tokens dejan de ser válidos antes de caducar: al cerrar todas sus sesiones,
+// al suspenderlo y al borrar la cuenta.
+func DisconnectUser(userID int) {
+	event := newEvent(EventSessionRevoked, 0, nil, "", UserTopic(userID))
+	event.UserID = userID
+	publishEvent(event)
+}
+
+// Función para enviar un evento a los clientes suscritos a alguno de sus topics.
+// No escribe en los sockets: publica el evento en el broker, que lo entrega al hub
+// de cada instancia, y avisa a los listeners registrados.
+func Broadcast(event Event) {
+	publishEvent(event)
+	notifyListeners(event)
 }
@@ -2,21 +2,35 @@
 
 import { useEffect } from "react";
 import { useRouter, useSearchParams } from "next/navigation";
+import { jwtDecode } from "jwt-decode";
+import { refreshAccessToken } from "@/lib/api/session";
+import { useAuthStore } from "@/store/useAuthStore";
 
 export default function AuthCallback() {
   const router = useRouter();
   const searchParams = useSearchParams();
+  const setUserId = useAuthStore((state) => state.setUserId);
 
   useEffect(() => {
-    const token = searchParams.get("token");
+    // El backend solo devuelve rutas propias ya validadas
+    const redirectTo = searchParams.get("redirect_to");
+    const error = searchParams.get("error");
 
-    if (token) {
-      localStorage.setItem("token", token);
-      router.push("/profile");
-    } else {
-      router.push("/");
+    if (error) {
+      router.push("/?login_error=" + encodeURIComponent(error));
+      return;
     }
-  }, [router, searchParams]);
+
+    // La sesión está en una cookie httpOnly: se canjea por un token de acceso
+    refreshAccessToken().then((token) => {
+      if (token) {
+        setUserId(jwtDecode<{ user_id: number }>(token).user_id);
+        router.push(redirectTo?.startsWith("/") && !redirectTo.startsWith("//") ? redirectTo : "/profile");
+      } else {
+        router.push("/");
+      }
+    });
+  }, [router, searchParams, setUserId]);
 
   return (
     <div className="flex items-center justify-center h-64">
@@ -24,4 +38,3 @@ export default function AuthCallback() {
     </div>
   );
 }
-// Compare this snippet from torneos-frontend/src/components/ui/button.tsx:
\ No newline at end of file
@@ -79,8 +79,8 @@ export default function ProfilePage() {
       alert(`Error al actualizar redes sociales: ${err.error}`);
     }
   };
-  const handleLogout = () => {
-    logout();
+  const handleLogout = async () => {
+    await logout();
     router.push("/");
   };
 
@@ -1,40 +1,60 @@
 "use client";
 
+import { usePathname } from "next/navigation";
 import { useWebSocket } from "@/lib/hooks/useWebSocket";
 import { toast } from "sonner";
 
+interface RealtimeEvent {
+  type: string;
+  version: number;
+  timestamp: string;
+  tournament_id?: number;
+  payload: unknown;
+}
+
 export function WebSocketListener() {
+  // En la página de un torneo escuchamos también sus eventos
+  const pathname = usePathname();
+  const tournamentMatch = pathname?.match(/^\/tournaments\/(\d+)/);
+  const topics = tournamentMatch ? ["global", `tournament:${tournamentMatch[1]}`] : ["global"];
+
   useWebSocket("/ws", (msg) => {
   console.log("[WebSocket] Mensaje recibido:", msg);
 
-  const parts = msg.split("|").reduce((acc, part) => {
-    const [key, value] = part.split(":");
-    if (key && value) {
-      acc[key.trim()] = value.trim();
-    }
-    return acc;
-  }, {} as Record<string, string>);
-
-  const event = parts["EVENT"];
-  const tournamentId = parseInt(parts["TOURNAMENT"] || "0");
+  let event: RealtimeEvent;
+  try {
+    event = JSON.parse(msg);
+  } catch {
+    console.log("[WebSocket] Mensaje no válido:", msg);
+    return;
+  }
 
-  console.log("[WebSocket] Event:", event, "Tournament:", tournamentId);
+  console.log("[WebSocket] Event:", event.type, "Tournament:", event.tournament_id);
 
-  // Mostrar toast según EVENT
-  if (event === "BRACKET") {
+  // Mostrar toast según el tipo de evento
+  if (event.type === "bracket_generated") {
     toast.success("¡Se ha generado un bracket!");
-  } else if (event === "RESULT" || event === "MATCH_RESULT") {
+  } else if (event.type === "match_result") {
     toast.success("¡Se ha reportado un resultado!");
-  } else if (event === "WINNER") {
+  } else if (event.type === "tournament_finished") {
     setTimeout(() => {
         toast.success("¡El torneo ha finalizado!");
     }, 1000);
     setTimeout(() => {
         window.location.reload();
     }, 2000);
+  } else if (event.type === "achievement_unlocked") {
+    const payload = event.payload as { name?: string };
+    toast.success(`¡Logro desbloqueado: ${payload.name ?? ""}!`);
+  } else if (event.type === "match_ready") {
+    toast.success("¡Tu próximo match está listo!");
+  } else if (event.type === "opponent_reported") {
+    toast.info("Tu rival ha reportado el resultado de vuestro match");
+  } else if (["authenticated", "subscribed", "unsubscribed", "session_expired", "session_revoked", "presence_join", "presence_leave", "notification", "participant_joined"].includes(event.type)) {
+    return;
   } else {
     console.log("[WebSocket] Evento desconocido:", msg);
   }
-});
+}, topics);
     return null;
 }
@@ -3,6 +3,7 @@
 import { useEffect } from "react";
 import { jwtDecode } from "jwt-decode";
 import { useAuthStore } from "@/store/useAuthStore";
+import { REFRESH_MARGIN_MS, refreshAccessToken } from "@/lib/api/session";
 
 interface DecodedToken {
   user_id: number;
@@ -14,32 +15,33 @@ export default function SessionHandler() {
   const setUserId = useAuthStore((state) => state.setUserId);
 
   useEffect(() => {
-    const url = new URL(window.location.href);
-    const token = url.searchParams.get("token");
+    let timer: ReturnType<typeof setTimeout> | undefined;
 
-    if (token) {
+    const schedule = (token: string | null) => {
+      if (!token) {
+        setUserId(null);
+        return;
+      }
       try {
         const decoded = jwtDecode<DecodedToken>(token);
-        localStorage.setItem("token", token);
         setUserId(decoded.user_id);
-
-        url.searchParams.delete("token");
-        window.history.replaceState({}, "", url.toString());
+        const wait = Math.max(decoded.exp * 1000 - Date.now() - REFRESH_MARGIN_MS, 0);
+        timer = setTimeout(async () => schedule(await refreshAccessToken(token)), wait);
       } catch (e) {
         console.error("Token inválido:", e);
+        setUserId(null);
       }
+    };
+
+    const localToken = localStorage.getItem("token");
+    if (localToken) {
+      schedule(localToken);
     } else {
-      const localToken = localStorage.getItem("token");
-      if (localToken) {
-        try {
-          const decoded = jwtDecode<DecodedToken>(localToken);
-          setUserId(decoded.user_id);
-        } catch (e) {
-          console.error("Token inválido en localStorage:", e);
-        }
-      }
+      refreshAccessToken().then(schedule);
     }
+
+    return () => clearTimeout(timer);
   }, [setUserId]);
 
   return null;
-}
\ No newline at end of file
+}
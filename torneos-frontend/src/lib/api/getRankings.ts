@@ -1,3 +1,5 @@
+import { authHeaders } from "./session";
+
 export interface RankingUser {
   id: number;
   username: string;
@@ -7,6 +9,9 @@ export interface RankingUser {
 export async function getRankings(): Promise<RankingUser[]> {
   const res = await fetch(`${process.env.NEXT_PUBLIC_BACKEND_URL}/api/ranking`, {
     cache: "no-store",
+    // Con sesión también aparecen los jugadores que solo comparten sus estadísticas con
+    // usuarios registrados
+    headers: authHeaders(),
   });
 
   if (!res.ok) {
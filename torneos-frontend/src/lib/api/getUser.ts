@@ -1,4 +1,5 @@
 import axios from "axios";
+import { authHeaders } from "./session";
 
 export interface User {
   id: number;
@@ -10,7 +11,9 @@ export interface User {
 
 export async function getUser(id: number): Promise<User | null> {
   try {
-    const res = await axios.get(`${process.env.NEXT_PUBLIC_BACKEND_URL}/api/users/${id}`);
+    const res = await axios.get(`${process.env.NEXT_PUBLIC_BACKEND_URL}/api/users/${id}`, {
+      headers: authHeaders(),
+    });
     return res.data;
   } catch (err) {
     console.error("Error al obtener usuario:", err);
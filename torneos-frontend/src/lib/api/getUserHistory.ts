@@ -1,3 +1,5 @@
+import { authHeaders } from "./session";
+
 export interface UserTournamentHistoryEntry {
   tournament_id: number;
   name: string;
@@ -9,6 +11,7 @@ export interface UserTournamentHistoryEntry {
 export async function getUserHistory(userId: number): Promise<UserTournamentHistoryEntry[]> {
   const res = await fetch(`${process.env.NEXT_PUBLIC_BACKEND_URL}/api/users/${userId}/history`, {
     cache: "no-store",
+    headers: authHeaders(),
   });
 
   if (!res.ok) {
@@ -1,3 +1,5 @@
+import { authHeaders } from "./session";
+
 export interface UserMatchEntry {
   match_id: number;
   tournament_id: number;
@@ -11,6 +13,7 @@ export interface UserMatchEntry {
 export async function getUserMatches(userId: number): Promise<UserMatchEntry[]> {
   const res = await fetch(`${process.env.NEXT_PUBLIC_BACKEND_URL}/api/users/${userId}/matches`, {
     cache: "no-store",
+    headers: authHeaders(),
   });
 
   if (!res.ok) {
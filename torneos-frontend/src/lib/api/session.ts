@@ -0,0 +1,87 @@
+import { jwtDecode } from "jwt-decode";
+
+const BACKEND_URL = process.env.NEXT_PUBLIC_BACKEND_URL;
+
+// Cabecera con el token de acceso, si hay sesión. Algunas secciones del perfil solo se
+// devuelven a usuarios registrados o a su dueño.
+export function authHeaders(): Record<string, string> {
+  const token = typeof window !== "undefined" ? localStorage.getItem("token") : null;
+  return token ? { Authorization: `Bearer ${token}` } : {};
+}
+
+// Margen antes de la caducidad del token de acceso para renovarlo
+export const REFRESH_MARGIN_MS = 60_000;
+
+// Indica si el token sigue siendo válido más allá del margen de renovación
+function isFresh(token: string | null): token is string {
+  if (!token) return false;
+  try {
+    return jwtDecode<{ exp: number }>(token).exp * 1000 - Date.now() > REFRESH_MARGIN_MS;
+  } catch {
+    return false;
+  }
+}
+
+// Pide un token de acceso nuevo con el refresh token de la cookie httpOnly y lo guarda.
+// Devuelve null si no hay sesión. Las pestañas comparten la cookie: la renovación se hace
+// bajo un lock y, si otra pestaña ya ha sustituido el token current que se quiere renovar,
+// se usa el suyo en lugar de volver a presentar un refresh token que ya se ha rotado.
+export async function refreshAccessToken(current: string | null = null): Promise<string | null> {
+  if (typeof navigator !== "undefined" && navigator.locks) {
+    return navigator.locks.request("refresh-token", () => refreshOnce(current));
+  }
+  return refreshOnce(current);
+}
+
+async function refreshOnce(current: string | null): Promise<string | null> {
+  const stored = localStorage.getItem("token");
+  if (current !== null && stored !== current && isFresh(stored)) {
+    return stored;
+  }
+
+  try {
+    const res = await fetch(`${BACKEND_URL}/auth/refresh`, {
+      method: "POST",
+      credentials: "include",
+    });
+    if (!res.ok) {
+      localStorage.removeItem("token");
+      return null;
+    }
+    const data = await res.json();
+    localStorage.setItem("token", data.token);
+    return data.token;
+  } catch (err) {
+    console.error("Error al renovar la sesión:", err);
+    return null;
+  }
+}
+
+export async function logoutSession(allDevices = false): Promise<void> {
+  const token = localStorage.getItem("token");
+  try {
+    await fetch(`${BACKEND_URL}/auth/${allDevices ? "logout-all" : "logout"}`, {
+      method: "POST",
+      credentials: "include",
+      headers: token ? { Authorization: `Bearer ${token}` } : undefined,
+    });
+  } catch (err) {
+    console.error("Error al cerrar sesión:", err);
+  }
+  localStorage.removeItem("token");
+}
+
+// Empieza a vincular otro proveedor a la cuenta. El backend guarda el estado en una
+// cookie httpOnly, así que la petición lleva credenciales; luego se sigue al proveedor.
+export async function startProviderLink(provider: string): Promise<void> {
+  const res = await fetch(`${BACKEND_URL}/api/auth/link/${provider}`, {
+    method: "POST",
+    credentials: "include",
+    headers: authHeaders(),
+  });
+  const data = await res.json();
+  if (!res.ok) {
+    throw new Error(data.error || "No se pudo iniciar la vinculación");
+  }
+  window.location.href = data.url;
+}
@@ -1,34 +1,78 @@
 import { useEffect, useRef } from "react";
 
-export function useWebSocket(path: string, onMessage: (msg: string) => void) {
+const RECONNECT_DELAY_MS = 3000;
+
+export function useWebSocket(path: string, onMessage: (msg: string) => void, topics: string[] = ["global"]) {
   const wsRef = useRef<WebSocket | null>(null);
+  // Último evento recibido, para pedir los perdidos al reconectar
+  const lastEventIdRef = useRef<number | null>(null);
+  const topicsKey = topics.join(",");
 
   useEffect(() => {
     const wsUrl = `${process.env.NEXT_PUBLIC_BACKEND_URL?.replace(/^http/, "ws")}${path}`;
-    const ws = new WebSocket(wsUrl);
+    let closedByUs = false;
+    let reconnectTimer: ReturnType<typeof setTimeout> | null = null;
 
-    wsRef.current = ws;
+    const connect = () => {
+      const ws = new WebSocket(wsUrl);
+      wsRef.current = ws;
 
-    ws.onopen = () => {
-      console.log("[WebSocket] Conectado a", wsUrl);
-    };
+      ws.onopen = () => {
+        console.log("[WebSocket] Conectado a", wsUrl);
+        const resume = lastEventIdRef.current !== null ? { last_event_id: lastEventIdRef.current } : {};
 
-    ws.onmessage = (event) => {
-      console.log("[WebSocket] Mensaje recibido:", event.data);
-      onMessage(event.data);
-    };
+        // Autenticar el socket para recibir también las notificaciones privadas
+        const token = localStorage.getItem("token");
+        if (token) {
+          ws.send(JSON.stringify({ action: "auth", token, ...resume }));
+        }
+        ws.send(JSON.stringify({ action: "subscribe", topics: topicsKey.split(","), ...resume }));
+      };
 
-    ws.onerror = (err) => {
-      console.error("[WebSocket] Error:", err);
-    };
+      ws.onmessage = (event) => {
+        console.log("[WebSocket] Mensaje recibido:", event.data);
+        try {
+          const parsed = JSON.parse(event.data);
+          if (typeof parsed.id === "number") {
+            if (lastEventIdRef.current !== null && parsed.id <= lastEventIdRef.current) {
+              return; // ya recibido
+            }
+            lastEventIdRef.current = parsed.id;
+          }
+          // El token del socket ha caducado: se vuelve a autenticar con el token renovado
+          if (parsed.type === "session_expired") {
+            const token = localStorage.getItem("token");
+            if (token) {
+              ws.send(JSON.stringify({ action: "auth", token }));
+            }
+          }
+        } catch {
+          // mensaje no JSON: se entrega tal cual
+        }
+        onMessage(event.data);
+      };
+
+      ws.onerror = (err) => {
+        console.error("[WebSocket] Error:", err);
+      };
 
-    ws.onclose = () => {
-      console.log("[WebSocket] Desconectado");
+      ws.onclose = () => {
+        console.log("[WebSocket] Desconectado");
+        if (!closedByUs) {
+          reconnectTimer = setTimeout(connect, RECONNECT_DELAY_MS);
+        }
+      };
     };
 
+    connect();
+
     // Limpieza
     return () => {
-      ws.close();
+      closedByUs = true;
+      if (reconnectTimer) {
+        clearTimeout(reconnectTimer);
+      }
+      wsRef.current?.close();
     };
-  }, [path, onMessage]);
+  }, [path, onMessage, topicsKey]);
 }
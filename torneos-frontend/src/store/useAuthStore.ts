@@ -1,9 +1,10 @@
 import { create } from "zustand";
+import { logoutSession } from "@/lib/api/session";
 
 interface AuthState {
   userId: number | null;
   setUserId: (id: number | null) => void;
-  logout: () => void;
+  logout: (allDevices?: boolean) => Promise<void>;
 }
 
 export const useAuthStore = create<AuthState>((set) => ({
@@ -11,8 +12,8 @@ export const useAuthStore = create<AuthState>((set) => ({
 
   setUserId: (id) => set({ userId: id }),
 
-  logout: () => {
-    localStorage.removeItem("token");
+  logout: async (allDevices = false) => {
+    await logoutSession(allDevices);
     set({ userId: null });
   },
-}));
\ No newline at end of file
+}));
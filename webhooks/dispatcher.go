@@ -0,0 +1,181 @@
+package webhooks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"torneos/database"
+	"torneos/models"
+	"torneos/realtime"
+)
+
+const (
+	// Cada cuánto se buscan entregas pendientes si nadie despierta al dispatcher
+	pollInterval = 10 * time.Second
+
+	// Entregas reclamadas por vuelta
+	claimBatch = 20
+
+	// Tiempo que una entrega reclamada queda reservada para esta instancia
+	claimLease = 2 * time.Minute
+
+	deliveryTimeout = 10 * time.Second
+
+	// Reintentos con backoff exponencial: 30s, 1m, 2m, 4m... hasta maxBackoff
+	maxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+)
+
+var (
+	httpClient = newHTTPClient()
+
+	wake    = make(chan struct{}, 1)
+	stop    = make(chan struct{})
+	stopped sync.WaitGroup
+	once    sync.Once
+)
+
+// Start registra los webhooks como listener de los eventos de tiempo real y arranca
+// el dispatcher que envía las entregas pendientes
+func Start() {
+	once.Do(func() {
+		realtime.AddListener(handleEvent)
+
+		stopped.Add(1)
+		go run()
+	})
+}
+
+// Stop detiene el dispatcher. Las entregas pendientes se envían al volver a arrancar.
+func Stop() {
+	select {
+	case <-stop:
+	default:
+		close(stop)
+	}
+	stopped.Wait()
+}
+
+func wakeUp() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func run() {
+	defer stopped.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		dispatchPending()
+
+		select {
+		case <-ticker.C:
+		case <-wake:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// dispatchPending envía las entregas pendientes hasta vaciar la cola
+func dispatchPending() {
+	for {
+		deliveries, err := database.ClaimWebhookDeliveries(claimBatch, claimLease)
+		if err != nil {
+			log.Printf("Error obteniendo entregas de webhooks pendientes: %v", err)
+			return
+		}
+
+		for _, d := range deliveries {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			deliver(d)
+		}
+
+		if len(deliveries) < claimBatch {
+			return
+		}
+	}
+}
+
+func deliver(d models.WebhookDelivery) {
+	statusCode, err := send(d)
+	if err == nil {
+		if err := database.MarkWebhookDelivered(d.ID, statusCode); err != nil {
+			log.Printf("Error registrando la entrega %d: %v", d.ID, err)
+		}
+		return
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	var next *time.Time
+	if attempt := d.Attempts + 1; attempt < maxAttempts {
+		t := time.Now().Add(backoff(attempt))
+		next = &t
+	}
+
+	if err := database.MarkWebhookAttemptFailed(d.ID, code, err.Error(), next); err != nil {
+		log.Printf("Error registrando el fallo de la entrega %d: %v", d.ID, err)
+	}
+}
+
+// send hace la petición firmada. Cualquier respuesta que no sea 2xx es un fallo.
+func send(d models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Torneos-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, d.Payload))
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return 0, errors.New(deliveryError(err))
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("respuesta %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// backoff devuelve la espera antes del siguiente intento tras attempt intentos fallidos
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << (attempt - 1)
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
+
+// Redeliver despierta al dispatcher tras encolar un reenvío manual
+func Redeliver() {
+	wakeUp()
+}
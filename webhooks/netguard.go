@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errBlockedAddress se devuelve al intentar conectar con una dirección interna
+var errBlockedAddress = errors.New("la URL del webhook apunta a una dirección no permitida")
+
+// Rangos que no son loopback, privados ni link-local según net.IP pero tampoco son
+// destinos públicos
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64
+}
+
+// publicAddr indica si se pueden enviar webhooks a la IP. Se rechazan loopback, redes
+// privadas, link-local (incluida la IP de metadatos 169.254.169.254) y multicast.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost resuelve el host y falla si alguna de sus direcciones no es pública
+func checkHost(host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(ip) {
+			return errBlockedAddress
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return errors.New("no se pudo resolver el host del webhook")
+	}
+	for _, ip := range addrs {
+		if !publicAddr(ip) {
+			return errBlockedAddress
+		}
+	}
+	return nil
+}
+
+// newHTTPClient crea el cliente de las entregas. La dirección se comprueba al conectar,
+// ya resuelta, para que un DNS que cambie tras el registro no sirva para llegar a la red
+// interna. No se siguen redirecciones ni se usa el proxy del entorno.
+func newHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: deliveryTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(addrPort.Addr()) {
+				return errBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: deliveryTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// deliveryError resume el error de la entrega para el registro que ve el organizador,
+// sin detalles de la conexión
+func deliveryError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errBlockedAddress):
+		return errBlockedAddress.Error()
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "tiempo de espera agotado"
+	default:
+		return "no se pudo conectar con el destino"
+	}
+}
@@ -0,0 +1,75 @@
+package webhooks
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"2606:4700:4700::1111", true},
+		{"::ffff:8.8.8.8", true},
+
+		// Loopback
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		// IPv4 mapeada en IPv6
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		// NAT64
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b::808:808", false},
+		// CGNAT
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		// Metadatos de la nube y link-local
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		// Privadas
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		// Otros rangos no públicos
+		{"0.0.0.0", false},
+		{"::", false},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Fatalf("publicAddr(%s) = %v, se esperaba %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicAddrInvalid(t *testing.T) {
+	if publicAddr(netip.Addr{}) {
+		t.Fatal("una dirección vacía no debe ser pública")
+	}
+}
+
+func TestCheckHostLiteral(t *testing.T) {
+	if err := checkHost("169.254.169.254"); !errors.Is(err, errBlockedAddress) {
+		t.Fatalf("checkHost(169.254.169.254) = %v, se esperaba errBlockedAddress", err)
+	}
+	if err := checkHost("::ffff:127.0.0.1"); !errors.Is(err, errBlockedAddress) {
+		t.Fatalf("checkHost(::ffff:127.0.0.1) = %v, se esperaba errBlockedAddress", err)
+	}
+	if err := checkHost("8.8.8.8"); err != nil {
+		t.Fatalf("checkHost(8.8.8.8) = %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"torneos/database"
+	"torneos/models"
+	"torneos/realtime"
+)
+
+// Cabeceras de cada entrega. La firma es HMAC-SHA256 con el secreto del webhook sobre
+// "<timestamp>.<cuerpo>", en hexadecimal y con el prefijo "sha256=".
+const (
+	HeaderEvent     = "X-Torneos-Event"
+	HeaderDelivery  = "X-Torneos-Delivery"
+	HeaderTimestamp = "X-Torneos-Timestamp"
+	HeaderSignature = "X-Torneos-Signature"
+)
+
+// Eventos de tiempo real que se reenvían a los webhooks
+var eventTypes = map[realtime.EventType]string{
+	realtime.EventBracketGenerated:   models.WebhookBracketGenerated,
+	realtime.EventMatchResult:        models.WebhookMatchCompleted,
+	realtime.EventTournamentFinished: models.WebhookTournamentFinished,
+	realtime.EventParticipantJoined:  models.WebhookParticipantJoined,
+}
+
+// Payload es el cuerpo JSON que recibe el webhook
+type Payload struct {
+	Event        string      `json:"event"`
+	TournamentID int         `json:"tournament_id"`
+	Timestamp    time.Time   `json:"timestamp"`
+	Data         interface{} `json:"data"`
+}
+
+// handleEvent encola una entrega para cada webhook del torneo suscrito al evento
+func handleEvent(event realtime.Event) {
+	eventType, ok := eventTypes[event.Type]
+	if !ok || event.TournamentID == 0 {
+		return
+	}
+
+	body, err := json.Marshal(Payload{
+		Event:        eventType,
+		TournamentID: event.TournamentID,
+		Timestamp:    event.Timestamp,
+		Data:         event.Payload,
+	})
+	if err != nil {
+		log.Printf("Error serializando el webhook %s: %v", eventType, err)
+		return
+	}
+
+	queued, err := database.EnqueueWebhookDeliveries(event.TournamentID, eventType, body)
+	if err != nil {
+		log.Printf("Error encolando el webhook %s del torneo %d: %v", eventType, event.TournamentID, err)
+		return
+	}
+	if queued > 0 {
+		wakeUp()
+	}
+}
+
+// Sign calcula la firma de un cuerpo para la cabecera X-Torneos-Signature
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret genera el secreto de firma de un webhook nuevo
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// ValidateURL comprueba que la URL de destino sea http(s) absoluta y que su host no
+// resuelva a una dirección interna. Al enviar se vuelve a comprobar la IP conectada.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("la URL del webhook debe ser http(s) absoluta")
+	}
+	return checkHost(u.Hostname())
+}
+
+// ValidEvent indica si un webhook puede suscribirse al evento
+func ValidEvent(event string) bool {
+	for _, e := range models.WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
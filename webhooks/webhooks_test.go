@@ -0,0 +1,50 @@
+package webhooks
+
+import "testing"
+
+// Los vectores se han calculado aparte (HMAC-SHA256 de "<timestamp>.<cuerpo>") para
+// que un cambio en el formato firmado rompa la prueba
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{
+			secret:    "whsec_test",
+			timestamp: 1700000000,
+			body:      `{"event":"match.completed","tournament_id":5}`,
+			want:      "sha256=8345b81fd97bb4183038cb5aed1434337cf20c9bd72b121b2f08d6abc31130f5",
+		},
+		{
+			secret:    "secreto",
+			timestamp: 0,
+			body:      "",
+			want:      "sha256=3eef90b82ba2199dd1488737f7e3cabcf496bcdc450f8f8e6f00bd27ef8d13cf",
+		},
+		{
+			secret:    "Jefe",
+			timestamp: 1234567890,
+			body:      "what do ya want for nothing?",
+			want:      "sha256=b3f26e8a05d33d7914321ad459529599917b9b9622fb8b836288b4edc72abdaa",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %d, %q) = %s, se esperaba %s", tt.secret, tt.timestamp, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestSignDependsOnSecretAndTimestamp(t *testing.T) {
+	body := []byte(`{"event":"tournament.finished"}`)
+	base := Sign("a", 1700000000, body)
+	if Sign("b", 1700000000, body) == base {
+		t.Error("la firma no cambia con el secreto")
+	}
+	if Sign("a", 1700000001, body) == base {
+		t.Error("la firma no cambia con el timestamp")
+	}
+}